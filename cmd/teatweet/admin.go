@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+
+	"github.com/hiendaovinh/toolkit/pkg/arr"
+	"github.com/phinc275/teatweet/internal/twitter"
+)
+
+// adminOnly guards an admin handler with a bearer token. Without a token, admin endpoints are disabled.
+func adminOnly(token string, handler http.HandlerFunc) http.HandlerFunc {
+	if token == "" {
+		return func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "admin endpoints are disabled, see --admin-token", http.StatusForbidden)
+		}
+	}
+
+	expected := []byte("Bearer " + token)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func addAccountHandlerFn(crawler *twitter.Crawler) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var credential twitter.Credential
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&credential)
+		if err != nil {
+			respJSON(w, nil, invalidArgument("invalid credential"))
+			return
+		}
+		if !isValidScreenName(credential.Username) {
+			respJSON(w, nil, invalidArgument("invalid username"))
+			return
+		}
+
+		err = crawler.AddCredential(r.Context(), credential)
+		if err != nil {
+			respJSON(w, nil, err)
+			return
+		}
+
+		respJSON(w, map[string]string{"username": credential.Username}, nil)
+	}
+}
+
+func removeAccountHandlerFn(crawler *twitter.Crawler) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := pathParam(r, "username")
+		err := crawler.RemoveAccount(username)
+		if err != nil {
+			respJSON(w, nil, err)
+			return
+		}
+
+		respJSON(w, map[string]string{"username": username}, nil)
+	}
+}
+
+func accountsHandlerFn(crawler *twitter.Crawler) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		respJSON(w, crawler.Accounts(), nil)
+	}
+}
+
+func reloginAccountHandlerFn(crawler *twitter.Crawler) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := pathParam(r, "username")
+		err := crawler.Relogin(r.Context(), username)
+		if err != nil {
+			respJSON(w, nil, err)
+			return
+		}
+
+		respJSON(w, map[string]string{"username": username}, nil)
+	}
+}
+
+func disableAccountHandlerFn(crawler *twitter.Crawler, disabled bool) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := pathParam(r, "username")
+		err := crawler.SetAccountDisabled(username, disabled)
+		if err != nil {
+			respJSON(w, nil, err)
+			return
+		}
+
+		respJSON(w, map[string]string{"username": username}, nil)
+	}
+}
+
+func clearForbiddenHandlerFn(crawler *twitter.Crawler) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := pathParam(r, "username")
+		call := r.URL.Query().Get("api")
+		if _, ok := arr.ArrFind(twitter.APICalls(), call); call != "" && !ok {
+			respJSON(w, nil, invalidArgument("invalid api"))
+			return
+		}
+
+		err := crawler.ClearForbidden(username, call)
+		if err != nil {
+			respJSON(w, nil, err)
+			return
+		}
+
+		respJSON(w, map[string]string{"username": username}, nil)
+	}
+}
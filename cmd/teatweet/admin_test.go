@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminOnly(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}
+
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		statusCode    int
+	}{
+		{"missing", "secret", "", http.StatusUnauthorized},
+		{"wrong", "secret", "Bearer guess", http.StatusUnauthorized},
+		{"not bearer", "secret", "secret", http.StatusUnauthorized},
+		{"correct", "secret", "Bearer secret", http.StatusOK},
+		// without a token, admin endpoints are disabled whatever is sent
+		{"disabled", "", "Bearer ", http.StatusForbidden},
+		{"disabled without header", "", "", http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin/accounts", nil)
+			if test.authorization != "" {
+				req.Header.Set("Authorization", test.authorization)
+			}
+
+			w := httptest.NewRecorder()
+			adminOnly(test.token, ok)(w, req)
+			assert.Equal(t, test.statusCode, w.Code)
+			if test.statusCode == http.StatusUnauthorized {
+				assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+			}
+			if test.statusCode == http.StatusOK {
+				assert.Equal(t, "ok", w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/phinc275/teatweet/internal/twitter"
+)
+
+// loadCredentials reads the JSON credentials from path, or from TWITTER_CREDENTIALS if path is empty.
+func loadCredentials(path string) ([]twitter.Credential, error) {
+	credentialsBz := []byte(os.Getenv("TWITTER_CREDENTIALS"))
+	if path != "" {
+		var err error
+		credentialsBz, err = os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var credentials []twitter.Credential
+	err := json.Unmarshal(credentialsBz, &credentials)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse credentials: %v", err)
+	}
+
+	return credentials, nil
+}
+
+// watchCredentials syncs the crawler's account pool with the credentials file on SIGHUP,
+// and whenever the file is modified if interval is positive. It returns when ctx is done.
+func watchCredentials(ctx context.Context, crawler *twitter.Crawler, path string, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if interval > 0 && path != "" {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	modTime := credentialsModTime(path)
+	reload := func() {
+		credentials, err := loadCredentials(path)
+		if err != nil {
+			slog.Warn("cannot reload credentials", "error", err)
+			return
+		}
+		crawler.SyncCredentials(ctx, credentials)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			if path == "" {
+				slog.Warn("cannot reload credentials, no credentials file is given")
+				continue
+			}
+			slog.Info("reloading credentials", "path", path)
+			modTime = credentialsModTime(path)
+			reload()
+		case <-tick:
+			if t := credentialsModTime(path); !t.Equal(modTime) {
+				slog.Info("credentials file is modified, reloading credentials", "path", path)
+				modTime = t
+				reload()
+			}
+		}
+	}
+}
+
+func credentialsModTime(path string) time.Time {
+	if path == "" {
+		return time.Time{}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime()
+}
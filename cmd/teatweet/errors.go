@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/phinc275/teatweet/internal/twitter"
+)
+
+// machine-readable error codes carried in the "error" field of responses
+const (
+	errCodeInvalidArgument = "invalid_argument"
+	errCodeRateLimited     = "rate_limited"
+	errCodeNotFound        = "not_found"
+	errCodeSuspended       = "suspended"
+	errCodeProtected       = "protected"
+	errCodeUnauthorized    = "unauthorized"
+	errCodeUpstream        = "upstream_error"
+	errCodeNoClient        = "no_client"
+	errCodeTimeout         = "timeout"
+	errCodeCanceled        = "canceled"
+	errCodeInternal        = "internal"
+)
+
+// invalidArgumentError is returned when the request itself is malformed.
+type invalidArgumentError struct {
+	msg string
+}
+
+func (err *invalidArgumentError) Error() string {
+	return err.msg
+}
+
+func invalidArgument(msg string) error {
+	return &invalidArgumentError{msg: msg}
+}
+
+// classifyError maps err to an HTTP status code and a machine-readable error code.
+func classifyError(err error) (int, string) {
+	var invalidArgumentErr *invalidArgumentError
+	var apiErr *twitter.APIError
+	var statusErr *twitter.StatusError
+
+	switch {
+	case errors.As(err, &invalidArgumentErr):
+		return http.StatusBadRequest, errCodeInvalidArgument
+	case errors.Is(err, twitter.ErrRateLimited):
+		return http.StatusTooManyRequests, errCodeRateLimited
+	case errors.Is(err, twitter.ErrUserNotFound), errors.Is(err, twitter.ErrTweetNotFound), errors.Is(err, twitter.ErrNotFound),
+		errors.Is(err, twitter.ErrAccountNotFound):
+		return http.StatusNotFound, errCodeNotFound
+	case errors.Is(err, twitter.ErrSuspended):
+		return http.StatusForbidden, errCodeSuspended
+	case errors.Is(err, twitter.ErrProtected):
+		return http.StatusForbidden, errCodeProtected
+	case errors.Is(err, twitter.ErrUnauthorized):
+		// our account was rejected, not the caller
+		return http.StatusBadGateway, errCodeUnauthorized
+	case errors.As(err, &apiErr), errors.As(err, &statusErr):
+		return http.StatusBadGateway, errCodeUpstream
+	case errors.Is(err, twitter.ErrNoClient):
+		return http.StatusServiceUnavailable, errCodeNoClient
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, errCodeTimeout
+	case errors.Is(err, context.Canceled):
+		// nginx's "client closed request", nobody will read it anyway
+		return 499, errCodeCanceled
+	default:
+		return http.StatusInternalServerError, errCodeInternal
+	}
+}
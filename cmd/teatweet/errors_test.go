@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/phinc275/teatweet/internal/twitter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		statusCode int
+		errCode    string
+	}{
+		{"invalid argument", invalidArgument("invalid tweet id"), http.StatusBadRequest, errCodeInvalidArgument},
+		{"rate limited pool", &twitter.RateLimitError{Reset: 1700000000}, http.StatusTooManyRequests, errCodeRateLimited},
+		{"api error 88", &twitter.APIError{Errors: []twitter.Error{{Code: 88}}}, http.StatusTooManyRequests, errCodeRateLimited},
+		{"status 429", &twitter.StatusError{StatusCode: http.StatusTooManyRequests}, http.StatusTooManyRequests, errCodeRateLimited},
+		{"user not found", twitter.ErrUserNotFound, http.StatusNotFound, errCodeNotFound},
+		{"wrapped tweet not found", fmt.Errorf("wrapped: %w", twitter.ErrTweetNotFound), http.StatusNotFound, errCodeNotFound},
+		{"api error 34", &twitter.APIError{Errors: []twitter.Error{{Code: 34, Message: "Sorry, that page does not exist"}}}, http.StatusNotFound, errCodeNotFound},
+		{"account not found", twitter.ErrAccountNotFound, http.StatusNotFound, errCodeNotFound},
+		{"api error 63", &twitter.APIError{Errors: []twitter.Error{{Code: 63}}}, http.StatusForbidden, errCodeSuspended},
+		{"protected", twitter.ErrProtected, http.StatusForbidden, errCodeProtected},
+		{"status 401", &twitter.StatusError{StatusCode: http.StatusUnauthorized}, http.StatusBadGateway, errCodeUnauthorized},
+		{"bad cursor", &twitter.APIError{Errors: []twitter.Error{{Code: 214, Message: "Bad cursor"}}}, http.StatusBadGateway, errCodeUpstream},
+		{"status 500", &twitter.StatusError{StatusCode: http.StatusInternalServerError}, http.StatusBadGateway, errCodeUpstream},
+		{"no client", twitter.ErrNoClient, http.StatusServiceUnavailable, errCodeNoClient},
+		{"deadline", context.DeadlineExceeded, http.StatusGatewayTimeout, errCodeTimeout},
+		{"canceled", context.Canceled, 499, errCodeCanceled},
+		{"unknown", errors.New("boom"), http.StatusInternalServerError, errCodeInternal},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			statusCode, errCode := classifyError(test.err)
+			assert.Equal(t, test.statusCode, statusCode)
+			assert.Equal(t, test.errCode, errCode)
+		})
+	}
+}
+
+func TestSetRetryAfter(t *testing.T) {
+	defaultSeconds := strconv.Itoa(int(defaultRetryAfter.Seconds()))
+	tests := []struct {
+		name       string
+		err        error
+		retryAfter []string
+	}{
+		// rounded up to the second, the reset itself being in seconds
+		{"pool reset", &twitter.RateLimitError{Reset: time.Now().Add(90 * time.Second).Unix()}, []string{"89", "90"}},
+		{"pool reset is over", &twitter.RateLimitError{Reset: time.Now().Add(-time.Minute).Unix()}, []string{"1"}},
+		// Twitter does not say when its window resets
+		{"status 429", &twitter.StatusError{StatusCode: http.StatusTooManyRequests}, []string{defaultSeconds}},
+		{"api error 88", &twitter.APIError{Errors: []twitter.Error{{Code: 88}}}, []string{defaultSeconds}},
+		{"not rate limited", twitter.ErrNoClient, []string{""}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			respJSON(w, nil, test.err)
+			assert.Contains(t, test.retryAfter, w.Header().Get("Retry-After"))
+		})
+	}
+}
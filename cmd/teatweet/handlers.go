@@ -0,0 +1,663 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/hiendaovinh/toolkit/pkg/arr"
+	"github.com/phinc275/teatweet/internal/twitter"
+)
+
+type Following struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+}
+
+type Follower struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+}
+
+type User struct {
+	ID              string    `json:"id"`
+	Username        string    `json:"username"`
+	Name            string    `json:"name"`
+	Description     string    `json:"description"`
+	Location        string    `json:"location"`
+	CreatedAt       time.Time `json:"created_at"`
+	FollowersCount  int64     `json:"followers_count"`
+	FollowingCount  int64     `json:"following_count"`
+	StatusesCount   int64     `json:"statuses_count"`
+	Verified        bool      `json:"verified"`
+	BlueVerified    bool      `json:"blue_verified"`
+	Protected       bool      `json:"protected"`
+	Suspended       bool      `json:"suspended"`
+	ProfileImageURL string    `json:"profile_image_url"`
+}
+
+type UsersLookup struct {
+	Users      []User   `json:"users"`
+	Unresolved []string `json:"unresolved"`
+}
+
+type Reply struct {
+	TweetID        string    `json:"tweet_id"`
+	UserID         string    `json:"user_id"`
+	Text           string    `json:"text"`
+	NormalizedText string    `json:"normalized_text"`
+	CreatedAt      time.Time `json:"created_at"`
+	Hashtags       []string  `json:"hashtags"`
+	Symbols        []string  `json:"symbols"`
+	Sort           int64     `json:"sort"`
+}
+
+type Quote struct {
+	TweetID        string    `json:"tweet_id"`
+	UserID         string    `json:"user_id"`
+	Text           string    `json:"text"`
+	NormalizedText string    `json:"normalized_text"`
+	CreatedAt      time.Time `json:"created_at"`
+	Hashtags       []string  `json:"hashtags"`
+	Symbols        []string  `json:"symbols"`
+	Sort           int64     `json:"sort"`
+}
+
+type Retweet struct {
+	TweetID string `json:"tweet_id"`
+	UserID  string `json:"user_id"`
+	Sort    int64  `json:"sort"`
+}
+
+type Like struct {
+	TweetID string `json:"tweet_id"`
+	UserID  string `json:"user_id"`
+	Sort    int64  `json:"sort"`
+}
+
+type Status struct {
+	ID            string    `json:"id"`
+	UserID        string    `json:"user_id"`
+	Username      string    `json:"username"`
+	Name          string    `json:"name"`
+	CreatedAt     time.Time `json:"created_at"`
+	IsQuoteStatus bool      `json:"is_quote_status"`
+	ViewCount     int64     `json:"view_count"`
+	QuoteCount    int64     `json:"quote_count"`
+	ReplyCount    int64     `json:"reply_count"`
+	RetweetCount  int64     `json:"retweet_count"`
+	FavoriteCount int64     `json:"favorite_count"`
+}
+
+type Tweet struct {
+	ID                string    `json:"id"`
+	ConversationID    string    `json:"conversation_id"`
+	InReplyToStatusID string    `json:"in_reply_to_status_id,omitempty"`
+	UserID            string    `json:"user_id"`
+	Username          string    `json:"username"`
+	Name              string    `json:"name"`
+	Text              string    `json:"text"`
+	NormalizedText    string    `json:"normalized_text"`
+	CreatedAt         time.Time `json:"created_at"`
+	Hashtags          []string  `json:"hashtags"`
+	Symbols           []string  `json:"symbols"`
+	ViewCount         int64     `json:"view_count"`
+	QuoteCount        int64     `json:"quote_count"`
+	ReplyCount        int64     `json:"reply_count"`
+	RetweetCount      int64     `json:"retweet_count"`
+	FavoriteCount     int64     `json:"favorite_count"`
+	QuotedStatus      *Tweet    `json:"quoted_status,omitempty"`
+	RetweetedStatus   *Tweet    `json:"retweeted_status,omitempty"`
+}
+
+type Thread struct {
+	Tweets     []Tweet `json:"tweets"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+type Conversation struct {
+	Tweet   *Tweet   `json:"tweet,omitempty"`
+	Threads []Thread `json:"threads"`
+}
+
+var (
+	regexID         = regexp.MustCompile(`^\d{1,20}$`)
+	regexScreenName = regexp.MustCompile(`^[A-Za-z0-9_]{1,15}$`)
+)
+
+func isValidID(id string) bool {
+	return regexID.MatchString(id)
+}
+
+func isValidScreenName(screenName string) bool {
+	return regexScreenName.MatchString(screenName)
+}
+
+// fetchFn fetches a single page of items starting at cursor and returns the next cursor.
+type fetchFn[T any] func(ctx context.Context, cursor string) ([]T, string, error)
+
+func followingHandlerFn(crawler *twitter.Crawler) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		twitterUserID := r.URL.Query().Get("id")
+		if !isValidID(twitterUserID) {
+			respJSON(w, nil, invalidArgument("invalid user id"))
+			return
+		}
+
+		serveCrawl(w, r, func(ctx context.Context, cursor string) ([]twitter.Following, string, error) {
+			return crawler.Following(ctx, twitterUserID, cursor)
+		}, toFollowing)
+	}
+}
+
+func followersHandlerFn(crawler *twitter.Crawler) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		twitterUserID := r.URL.Query().Get("id")
+		if !isValidID(twitterUserID) {
+			respJSON(w, nil, invalidArgument("invalid user id"))
+			return
+		}
+
+		serveCrawl(w, r, func(ctx context.Context, cursor string) ([]twitter.Follower, string, error) {
+			return crawler.Followers(ctx, twitterUserID, cursor)
+		}, toFollower)
+	}
+}
+
+func repliesHandlerFn(crawler *twitter.Crawler) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tweetID := pathParam(r, "id")
+		if !isValidID(tweetID) {
+			respJSON(w, nil, invalidArgument("invalid tweet id"))
+			return
+		}
+
+		serveCrawl(w, r, func(ctx context.Context, cursor string) ([]twitter.Reply, string, error) {
+			return crawler.Replies(ctx, tweetID, cursor)
+		}, toReply)
+	}
+}
+
+func quotesHandlerFn(crawler *twitter.Crawler) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tweetID := pathParam(r, "id")
+		if !isValidID(tweetID) {
+			respJSON(w, nil, invalidArgument("invalid tweet id"))
+			return
+		}
+
+		serveCrawl(w, r, func(ctx context.Context, cursor string) ([]twitter.Quote, string, error) {
+			return crawler.Quotes(ctx, tweetID, cursor)
+		}, toQuote)
+	}
+}
+
+func retweetsHandlerFn(crawler *twitter.Crawler) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tweetID := pathParam(r, "id")
+		if !isValidID(tweetID) {
+			respJSON(w, nil, invalidArgument("invalid tweet id"))
+			return
+		}
+
+		serveCrawl(w, r, func(ctx context.Context, cursor string) ([]twitter.Retweet, string, error) {
+			return crawler.Retweets(ctx, tweetID, cursor)
+		}, toRetweet)
+	}
+}
+
+func likesHandlerFn(crawler *twitter.Crawler) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tweetID := pathParam(r, "id")
+		if !isValidID(tweetID) {
+			respJSON(w, nil, invalidArgument("invalid tweet id"))
+			return
+		}
+
+		serveCrawl(w, r, func(ctx context.Context, cursor string) ([]twitter.Like, string, error) {
+			return crawler.Likes(ctx, tweetID, cursor)
+		}, toLike)
+	}
+}
+
+// tweetHandlerFn serves a page of the conversation of a tweet, the tweet itself being only on the first page.
+func tweetHandlerFn(crawler *twitter.Crawler) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tweetID := pathParam(r, "id")
+		if !isValidID(tweetID) {
+			respJSON(w, nil, invalidArgument("invalid tweet id"))
+			return
+		}
+
+		conversation, nextCursor, err := crawler.TweetDetail(lookupContext(r), tweetID, r.URL.Query().Get("cursor"))
+		if err != nil {
+			respJSON(w, nil, err)
+			return
+		}
+		respJSONPage(w, toConversation(*conversation), nextCursor, nil)
+	}
+}
+
+func statusesHandlerFn(crawler *twitter.Crawler) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		screenName := pathParam(r, "screen_name")
+		if !isValidScreenName(screenName) {
+			respJSON(w, nil, invalidArgument("invalid screen name"))
+			return
+		}
+
+		serveCrawl(w, r, func(ctx context.Context, cursor string) ([]twitter.StatusStat, string, error) {
+			return crawler.StatusesByScreenName(ctx, screenName, cursor)
+		}, toStatus)
+	}
+}
+
+func userHandlerFn(crawler *twitter.Crawler) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		screenName := pathParam(r, "screen_name")
+		if !isValidScreenName(screenName) {
+			respJSON(w, nil, invalidArgument("invalid screen name"))
+			return
+		}
+
+		user, err := crawler.UserByScreenName(lookupContext(r), screenName)
+		if err != nil {
+			respJSON(w, nil, err)
+			return
+		}
+		respJSON(w, toUser(*user), nil)
+	}
+}
+
+func userByIDHandlerFn(crawler *twitter.Crawler) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := pathParam(r, "id")
+		if !isValidID(userID) {
+			respJSON(w, nil, invalidArgument("invalid user id"))
+			return
+		}
+
+		user, err := crawler.UserByRestID(lookupContext(r), userID)
+		if err != nil {
+			respJSON(w, nil, err)
+			return
+		}
+		respJSON(w, toUser(*user), nil)
+	}
+}
+
+// maxLookupIDs is the number of ids a single POST /users/lookup can ask for
+const maxLookupIDs = 5000
+
+func usersLookupHandlerFn(crawler *twitter.Crawler) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// lookups are interactive unless told otherwise, however many ids they ask for
+		priority, err := parsePriority(r, twitter.PriorityInteractive)
+		if err != nil {
+			respJSON(w, nil, err)
+			return
+		}
+
+		var body struct {
+			IDs []string `json:"ids"`
+		}
+		err = json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&body)
+		if err != nil {
+			respJSON(w, nil, invalidArgument("invalid body"))
+			return
+		}
+		if len(body.IDs) == 0 || len(body.IDs) > maxLookupIDs {
+			respJSON(w, nil, invalidArgument(fmt.Sprintf("between 1 and %d ids are required", maxLookupIDs)))
+			return
+		}
+		for _, id := range body.IDs {
+			if !isValidID(id) {
+				respJSON(w, nil, invalidArgument("invalid user id "+id))
+				return
+			}
+		}
+
+		users, failed, err := crawler.UsersByRestIDs(schedulingContext(r, pageOptions{Priority: priority}), body.IDs)
+		if err != nil {
+			respJSON(w, nil, err)
+			return
+		}
+
+		// ids of failed chunks are listed after those which cannot be found
+		known := make(map[string]bool, len(users)+len(failed))
+		lookup := UsersLookup{
+			Users:      make([]User, 0, len(users)),
+			Unresolved: make([]string, 0),
+		}
+		for _, user := range users {
+			known[user.ID] = true
+			lookup.Users = append(lookup.Users, toUser(user))
+		}
+		for _, id := range failed {
+			known[id] = true
+		}
+		for _, id := range arr.ArrUnique(body.IDs) {
+			if !known[id] {
+				lookup.Unresolved = append(lookup.Unresolved, id)
+			}
+		}
+		lookup.Unresolved = append(lookup.Unresolved, failed...)
+
+		respJSON(w, lookup, nil)
+	}
+}
+
+func queuesHandlerFn(crawler *twitter.Crawler) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		respJSON(w, crawler.QueueDepths(), nil)
+	}
+}
+
+// pageOptions controls client-driven pagination.
+// Without cursor and limit query parameters, the whole result set is crawled in one response.
+type pageOptions struct {
+	Paginate bool
+	Cursor   string
+	Limit    int
+	Priority twitter.Priority
+}
+
+func parsePageOptions(r *http.Request) (pageOptions, error) {
+	query := r.URL.Query()
+	opts := pageOptions{
+		Paginate: query.Has("cursor") || query.Has("limit"),
+		Cursor:   query.Get("cursor"),
+	}
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			return opts, invalidArgument("invalid limit")
+		}
+		opts.Limit = limit
+	}
+
+	// unbounded crawls are batch jobs unless told otherwise
+	priority := twitter.PriorityInteractive
+	if opts.Limit == 0 && (!opts.Paginate || isStreamRequest(r)) {
+		priority = twitter.PriorityBatch
+	}
+
+	var err error
+	opts.Priority, err = parsePriority(r, priority)
+	return opts, err
+}
+
+// parsePriority reads the priority query parameter of r, fallback is used if it is not given.
+func parsePriority(r *http.Request, fallback twitter.Priority) (twitter.Priority, error) {
+	switch r.URL.Query().Get("priority") {
+	case "":
+		return fallback, nil
+	case twitter.PriorityInteractive.String():
+		return twitter.PriorityInteractive, nil
+	case twitter.PriorityBatch.String():
+		return twitter.PriorityBatch, nil
+	default:
+		return fallback, invalidArgument("invalid priority")
+	}
+}
+
+// schedulingContext tags ctx with the priority and the caller of r, so the crawler can share the account pool fairly.
+// Callers are identified by the X-Caller-ID header, falling back to the remote address.
+func schedulingContext(r *http.Request, opts pageOptions) context.Context {
+	caller := r.Header.Get("X-Caller-ID")
+	if caller == "" {
+		caller = r.RemoteAddr
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			caller = host
+		}
+	}
+
+	ctx := twitter.WithPriority(r.Context(), opts.Priority)
+	return twitter.WithCaller(ctx, caller)
+}
+
+// lookupContext is the scheduling context of single lookups, which are always interactive.
+func lookupContext(r *http.Request) context.Context {
+	return schedulingContext(r, pageOptions{Priority: twitter.PriorityInteractive})
+}
+
+// serveCrawl crawls with fetch according to the request's page options and writes the converted items.
+func serveCrawl[T any, R any](w http.ResponseWriter, r *http.Request, fetch fetchFn[T], convert func(T) R) {
+	opts, err := parsePageOptions(r)
+	if err != nil {
+		respJSON(w, nil, err)
+		return
+	}
+
+	ctx := schedulingContext(r, opts)
+
+	if sw, ok := newStreamWriter(w, r); ok {
+		streamCrawl(ctx, sw, fetch, convert, opts.Cursor, opts.Limit)
+		return
+	}
+
+	if !opts.Paginate {
+		results, nextCursor, err := crawlPages(ctx, fetch, convert, "", 0)
+		if err != nil {
+			requestLogger(ctx).Warn("crawl failed", "cursor", nextCursor, "error", err)
+			respJSON(w, nil, err)
+			return
+		}
+		respJSON(w, results, nil)
+		return
+	}
+
+	limit := opts.Limit
+	if limit == 0 {
+		// a single non-empty page
+		limit = 1
+	}
+
+	results, nextCursor, err := crawlPages(ctx, fetch, convert, opts.Cursor, limit)
+	if err != nil {
+		requestLogger(ctx).Warn("crawl failed", "cursor", nextCursor, "error", err)
+	}
+	if err != nil && len(results) == 0 {
+		respJSON(w, nil, err)
+		return
+	}
+
+	// if a later page failed, return what we have so far, the caller resumes from nextCursor
+	if err != nil {
+		respJSONPartial(w, results, nextCursor, err)
+		return
+	}
+	respJSONPage(w, results, nextCursor, nil)
+}
+
+// crawlPages calls fetch starting at cursor until at least limit items are crawled or the cursor is exhausted.
+// Pages are never split, so more than limit items may be returned. A non-positive limit crawls everything.
+// On error, the items crawled so far are returned along with the cursor of the page that failed.
+func crawlPages[T any, R any](ctx context.Context, fetch fetchFn[T], convert func(T) R, cursor string, limit int) ([]R, string, error) {
+	results := make([]R, 0)
+	nextCursor, err := crawlEach(ctx, fetch, cursor, limit, func(items []T, _ string) error {
+		for _, item := range items {
+			results = append(results, convert(item))
+		}
+		return nil
+	})
+
+	return results, nextCursor, err
+}
+
+// crawlEach is like crawlPages but hands every page to onPage as soon as it is crawled.
+// Crawling stops as soon as onPage returns an error.
+func crawlEach[T any](ctx context.Context, fetch fetchFn[T], cursor string, limit int, onPage func(items []T, nextCursor string) error) (string, error) {
+	count := 0
+	for {
+		items, nextCursor, err := fetch(ctx, cursor)
+		if err != nil {
+			return cursor, err
+		}
+
+		err = onPage(items, nextCursor)
+		if err != nil {
+			return nextCursor, err
+		}
+
+		count += len(items)
+		cursor = nextCursor
+		if cursor == "" {
+			break
+		}
+
+		if limit > 0 && count >= limit {
+			break
+		}
+	}
+
+	return cursor, nil
+}
+
+func toFollowing(item twitter.Following) Following {
+	return Following{
+		ID:       item.UserID,
+		Username: item.ScreenName,
+		Name:     item.Name,
+	}
+}
+
+func toFollower(item twitter.Follower) Follower {
+	return Follower{
+		ID:       item.UserID,
+		Username: item.ScreenName,
+		Name:     item.Name,
+	}
+}
+
+func toUser(item twitter.User) User {
+	return User{
+		ID:              item.ID,
+		Username:        item.ScreenName,
+		Name:            item.Name,
+		Description:     item.Description,
+		Location:        item.Location,
+		CreatedAt:       item.CreatedAt,
+		FollowersCount:  item.FollowersCount,
+		FollowingCount:  item.FollowingCount,
+		StatusesCount:   item.StatusesCount,
+		Verified:        item.Verified,
+		BlueVerified:    item.BlueVerified,
+		Protected:       item.Protected,
+		Suspended:       item.Suspended,
+		ProfileImageURL: item.ProfileImageURL,
+	}
+}
+
+func toReply(item twitter.Reply) Reply {
+	return Reply{
+		TweetID:        item.TweetID,
+		UserID:         item.UserID,
+		Text:           item.Text,
+		NormalizedText: item.NormalizedText,
+		CreatedAt:      item.CreatedAt,
+		Hashtags:       item.Hashtags,
+		Symbols:        item.Symbols,
+		Sort:           item.Sort,
+	}
+}
+
+func toQuote(item twitter.Quote) Quote {
+	return Quote{
+		TweetID:        item.TweetID,
+		UserID:         item.UserID,
+		Text:           item.Text,
+		NormalizedText: item.NormalizedText,
+		CreatedAt:      item.CreatedAt,
+		Hashtags:       item.Hashtags,
+		Symbols:        item.Symbols,
+		Sort:           item.Sort,
+	}
+}
+
+func toRetweet(item twitter.Retweet) Retweet {
+	return Retweet{
+		TweetID: item.TweetID,
+		UserID:  item.UserID,
+		Sort:    item.Sort,
+	}
+}
+
+func toLike(item twitter.Like) Like {
+	return Like{
+		TweetID: item.TweetID,
+		UserID:  item.UserID,
+		Sort:    item.Sort,
+	}
+}
+
+func toStatus(item twitter.StatusStat) Status {
+	return Status{
+		ID:            item.ID,
+		UserID:        item.UserID,
+		Username:      item.UserScreenName,
+		Name:          item.UserName,
+		CreatedAt:     item.CreatedAt,
+		IsQuoteStatus: item.IsQuoteStatus,
+		ViewCount:     item.ViewCount,
+		QuoteCount:    item.QuoteCount,
+		ReplyCount:    item.ReplyCount,
+		RetweetCount:  item.RetweetCount,
+		FavoriteCount: item.FavoriteCount,
+	}
+}
+
+func toTweet(item twitter.Tweet) Tweet {
+	tweet := Tweet{
+		ID:                item.ID,
+		ConversationID:    item.ConversationID,
+		InReplyToStatusID: item.InReplyToStatusID,
+		UserID:            item.UserID,
+		Username:          item.UserScreenName,
+		Name:              item.UserName,
+		Text:              item.Text,
+		NormalizedText:    item.NormalizedText,
+		CreatedAt:         item.CreatedAt,
+		Hashtags:          item.Hashtags,
+		Symbols:           item.Symbols,
+		ViewCount:         item.ViewCount,
+		QuoteCount:        item.QuoteCount,
+		ReplyCount:        item.ReplyCount,
+		RetweetCount:      item.RetweetCount,
+		FavoriteCount:     item.FavoriteCount,
+	}
+	if item.QuotedStatus != nil {
+		quoted := toTweet(*item.QuotedStatus)
+		tweet.QuotedStatus = &quoted
+	}
+	if item.RetweetedStatus != nil {
+		retweeted := toTweet(*item.RetweetedStatus)
+		tweet.RetweetedStatus = &retweeted
+	}
+
+	return tweet
+}
+
+func toConversation(item twitter.Conversation) Conversation {
+	conversation := Conversation{Threads: make([]Thread, 0, len(item.Threads))}
+	if item.Tweet != nil {
+		tweet := toTweet(*item.Tweet)
+		conversation.Tweet = &tweet
+	}
+	for _, thread := range item.Threads {
+		conversation.Threads = append(conversation.Threads, Thread{
+			Tweets:     arr.ArrMap(thread.Tweets, toTweet),
+			NextCursor: thread.Cursor,
+		})
+	}
+
+	return conversation
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hiendaovinh/toolkit/pkg/arr"
+	"github.com/phinc275/teatweet/internal/twitter"
+	"github.com/phinc275/teatweet/internal/twitter/twittertest"
+	"github.com/stretchr/testify/assert"
+)
+
+// newFakeCrawler returns a crawler logged in to a fake Twitter with a single account.
+func newFakeCrawler(t *testing.T) (*twitter.Crawler, *twittertest.Server) {
+	fake := twittertest.NewServer(t)
+	fake.AddAccount("alice", "secret")
+
+	crawler, err := twitter.NewCrawler(
+		[]twitter.Credential{{Username: "alice", Password: "secret"}},
+		twitter.WithHosts(twitter.Hosts{Web: fake.URL, API: fake.URL}),
+		twitter.WithTransport(fake.Client().Transport),
+	)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = crawler.Close() })
+
+	return crawler, fake
+}
+
+// serveRoute serves req with handler registered at pattern, so that path parameters are set.
+func serveRoute(handler http.HandlerFunc, pattern string, req *http.Request) *httptest.ResponseRecorder {
+	rt := newRouter(nil)
+	rt.HandleFunc(req.Method, pattern, handler)
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, req)
+	return w
+}
+
+func TestParsePageOptions(t *testing.T) {
+	tests := []struct {
+		query    string
+		accept   string
+		expected pageOptions
+		err      bool
+	}{
+		{query: "", expected: pageOptions{Priority: twitter.PriorityBatch}},
+		{query: "limit=1", expected: pageOptions{Paginate: true, Limit: 1, Priority: twitter.PriorityInteractive}},
+		{query: "cursor=c", expected: pageOptions{Paginate: true, Cursor: "c", Priority: twitter.PriorityInteractive}},
+		{query: "cursor=c", accept: contentTypeNDJSON, expected: pageOptions{Paginate: true, Cursor: "c", Priority: twitter.PriorityBatch}},
+		{query: "limit=200&priority=batch", expected: pageOptions{Paginate: true, Limit: 200, Priority: twitter.PriorityBatch}},
+		{query: "priority=interactive", expected: pageOptions{Priority: twitter.PriorityInteractive}},
+		{query: "limit=0", err: true},
+		{query: "limit=-1", err: true},
+		{query: "limit=ten", err: true},
+		{query: "priority=urgent", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query+" "+test.accept, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/tweets/100/likes?"+test.query, nil)
+			req.Header.Set("Accept", test.accept)
+
+			opts, err := parsePageOptions(req)
+			if test.err {
+				var invalidArgumentErr *invalidArgumentError
+				assert.ErrorAs(t, err, &invalidArgumentErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, opts)
+		})
+	}
+}
+
+func TestServeCrawlPages(t *testing.T) {
+	crawler, fake := newFakeCrawler(t)
+	handler := likesHandlerFn(crawler)
+
+	type likesResp struct {
+		resp
+		Data []Like `json:"data"`
+	}
+	serve := func(url string) (*httptest.ResponseRecorder, likesResp) {
+		w := serveRoute(handler, "/tweets/{id}/likes", httptest.NewRequest(http.MethodGet, url, nil))
+		var r likesResp
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &r))
+		return w, r
+	}
+
+	// without cursor and limit, everything is crawled
+	w, r := serve("/tweets/100/likes")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, r.Data, 2)
+	assert.Empty(t, r.NextCursor)
+
+	// a single page
+	w, r = serve("/tweets/100/likes?limit=1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, r.Data, 2)
+	assert.Equal(t, "favoriters-page-2", r.NextCursor)
+	assert.False(t, r.Partial)
+
+	// the first page fails
+	fake.FailNext("Favoriters", http.StatusBadGateway)
+	w, r = serve("/tweets/100/likes?limit=10")
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Equal(t, errCodeUpstream, r.Error)
+	assert.Nil(t, r.Data)
+
+	// a later page is rate limited, the items so far are returned along with the cursor to resume from
+	fake.SetRateLimit("Favoriters", 1, time.Now().Add(time.Minute))
+	w, r = serve("/tweets/100/likes?limit=10")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, r.Partial)
+	assert.Len(t, r.Data, 2)
+	assert.Equal(t, "favoriters-page-2", r.NextCursor)
+	assert.Equal(t, errCodeRateLimited, r.Error)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+}
+
+func TestUsersLookup(t *testing.T) {
+	crawler, fake := newFakeCrawler(t)
+	handler := usersLookupHandlerFn(crawler)
+
+	ids := []string{"203", "666", "201", "203"}
+	for i := 0; i < 200; i++ {
+		ids = append(ids, strconv.Itoa(5000+i))
+	}
+	unique := arr.ArrUnique(ids)
+	body, _ := json.Marshal(map[string][]string{"ids": ids})
+
+	type lookupResp struct {
+		resp
+		Data UsersLookup `json:"data"`
+	}
+	serve := func(body []byte) (*httptest.ResponseRecorder, lookupResp) {
+		w := serveRoute(handler, "/users/lookup", httptest.NewRequest(http.MethodPost, "/users/lookup", bytes.NewReader(body)))
+		var r lookupResp
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &r))
+		return w, r
+	}
+
+	w, r := serve(body)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"carol", "alice"}, arr.ArrMap(r.Data.Users, func(user User) string { return user.Username }))
+	assert.Len(t, r.Data.Unresolved, len(unique)-2)
+
+	// the ids of a failed chunk are unresolved too, listed last
+	fake.FailNext("UsersByRestIds", http.StatusServiceUnavailable)
+	w, r = serve(body)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.ElementsMatch(t, unique, append(arr.ArrMap(r.Data.Users, func(user User) string { return user.ID }), r.Data.Unresolved...))
+	// chunks are sent at once, so any of them can be the one which fails
+	failed := arr.ArrFilter([][]string{unique[:100], unique[100:200], unique[200:]}, func(chunk []string) bool {
+		return len(r.Data.Unresolved) >= len(chunk) && assert.ObjectsAreEqual(chunk, r.Data.Unresolved[len(r.Data.Unresolved)-len(chunk):])
+	})
+	assert.NotEmpty(t, failed)
+
+	// the lookup fails only when every chunk does
+	fake.FailNext("UsersByRestIds", http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+	w, r = serve(body)
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Equal(t, errCodeUpstream, r.Error)
+
+	w, r = serve([]byte(`{"ids":["@alice"]}`))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, errCodeInvalidArgument, r.Error)
+}
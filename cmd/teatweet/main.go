@@ -3,13 +3,23 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"math"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/phinc275/teatweet/internal/twitter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/urfave/cli/v2"
 )
 
@@ -27,7 +37,8 @@ func main() {
 	}
 
 	if err := app.Run(os.Args); err != nil {
-		log.Fatal(err)
+		slog.Error(err.Error())
+		os.Exit(1)
 	}
 }
 
@@ -41,104 +52,313 @@ func newServeCommand() *cli.Command {
 				Value: "0.0.0.0:8001",
 				Usage: "serve address",
 			},
+			&cli.StringFlag{
+				Name:    "log-level",
+				Value:   "info",
+				EnvVars: []string{"LOG_LEVEL"},
+				Usage:   "debug, info, warn or error",
+			},
+			&cli.StringFlag{
+				Name:    "log-format",
+				Value:   "text",
+				EnvVars: []string{"LOG_FORMAT"},
+				Usage:   "text or json",
+			},
+			&cli.BoolFlag{
+				Name:  "wait-for-capacity",
+				Usage: "wait for a rate limited account pool to free up instead of failing right away, waiting requests are served by priority and round-robin across callers",
+			},
+			&cli.DurationFlag{
+				Name:  "shutdown-timeout",
+				Value: 30 * time.Second,
+				Usage: "how long active requests may run on SIGINT/SIGTERM before they are cancelled",
+			},
+			&cli.StringFlag{
+				Name:    "session-dir",
+				EnvVars: []string{"TWITTER_SESSION_DIR"},
+				Usage:   "directory to persist account sessions in, so that restarts do not log in again",
+			},
+			&cli.StringFlag{
+				Name:  "cassette-dir",
+				Usage: "directory to record the requests sent to Twitter in, or to replay them from, see --cassette-mode",
+			},
+			&cli.StringFlag{
+				Name:  "cassette-mode",
+				Value: "record",
+				Usage: "record or replay, replayed requests are not sent to Twitter",
+			},
+			&cli.StringFlag{
+				Name:    "credentials-file",
+				EnvVars: []string{"TWITTER_CREDENTIALS_FILE"},
+				Usage:   "JSON file to read credentials from instead of TWITTER_CREDENTIALS, reloaded on SIGHUP or when modified",
+			},
+			&cli.DurationFlag{
+				Name:  "credentials-poll-interval",
+				Value: 30 * time.Second,
+				Usage: "how often the credentials file is checked for modifications, 0 to reload on SIGHUP only",
+			},
+			&cli.IntFlag{
+				Name:  "forbidden-probe-attempts",
+				Value: 30,
+				Usage: "how many times an account forbidden by Twitter is re-probed before giving up, 0 to never give up",
+			},
+			&cli.DurationFlag{
+				Name:  "forbidden-probe-backoff",
+				Value: time.Minute,
+				Usage: "delay before re-probing an account forbidden by Twitter, doubled after every failed probe up to an hour",
+			},
+			&cli.IntFlag{
+				Name:  "reconnect-attempts",
+				Value: 10,
+				Usage: "how many times an account whose session was rejected tries to log in again before giving up, 0 to never give up",
+			},
+			&cli.StringFlag{
+				Name:    "admin-token",
+				EnvVars: []string{"TEATWEET_ADMIN_TOKEN"},
+				Usage:   "bearer token required by the /admin endpoints and /metrics, which are disabled without it",
+			},
 		},
 		Action: func(c *cli.Context) error {
-			credentialsStr := os.Getenv("TWITTER_CREDENTIALS")
-			var credentials []twitter.Credential
-			err := json.Unmarshal([]byte(credentialsStr), &credentials)
+			logger, err := newLogger(c.String("log-level"), c.String("log-format"))
+			if err != nil {
+				return err
+			}
+			slog.SetDefault(logger)
+
+			credentialsFile := c.String("credentials-file")
+			credentials, err := loadCredentials(credentialsFile)
 			if err != nil {
-				return fmt.Errorf("failed to parse credentials: %v", err)
+				return err
+			}
+
+			opts := []twitter.Option{
+				twitter.WithForbiddenProbe(twitter.BackoffPolicy{
+					Min:         c.Duration("forbidden-probe-backoff"),
+					Max:         time.Hour,
+					MaxAttempts: c.Int("forbidden-probe-attempts"),
+				}),
+				twitter.WithLogger(logger),
+				twitter.WithReconnectPolicy(twitter.BackoffPolicy{
+					Min:         5 * time.Second,
+					Max:         5 * time.Minute,
+					MaxAttempts: c.Int("reconnect-attempts"),
+				}),
 			}
 
-			crawler, err := twitter.NewCrawler(credentials)
+			registry := prometheus.NewRegistry()
+			registry.MustRegister(
+				collectors.NewGoCollector(),
+				collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+			)
+			opts = append(opts, twitter.WithMetrics(registry))
+
+			if c.Bool("wait-for-capacity") {
+				opts = append(opts, twitter.WithWaitForCapacity())
+			}
+			if sessionDir := c.String("session-dir"); sessionDir != "" {
+				sessionStore, err := twitter.NewFileSessionStore(sessionDir)
+				if err != nil {
+					return fmt.Errorf("failed to open session store: %v", err)
+				}
+				opts = append(opts, twitter.WithSessionStore(sessionStore))
+			}
+
+			if cassetteDir := c.String("cassette-dir"); cassetteDir != "" {
+				var mode twitter.CassetteMode
+				switch c.String("cassette-mode") {
+				case "record":
+					mode = twitter.CassetteRecord
+				case "replay":
+					mode = twitter.CassetteReplay
+				default:
+					return fmt.Errorf("invalid cassette mode: %s", c.String("cassette-mode"))
+				}
+
+				cassette, err := twitter.NewCassette(cassetteDir, mode, nil)
+				if err != nil {
+					return fmt.Errorf("failed to open cassette: %v", err)
+				}
+				opts = append(opts, twitter.WithTransport(cassette))
+			}
+
+			crawler, err := twitter.NewCrawler(credentials, opts...)
 			if err != nil {
 				return fmt.Errorf("failed to initiate crawler: %v", err)
 			}
 
-			http.HandleFunc("/following", followingHandlerFn(crawler))
-			http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			// cancelled once the shutdown timeout is over, aborting the crawls still in flight
+			baseCtx, cancelBase := context.WithCancel(context.Background())
+			defer cancelBase()
+
+			go watchCredentials(baseCtx, crawler, credentialsFile, c.Duration("credentials-poll-interval"))
+
+			rt := newRouter(func(w http.ResponseWriter, r *http.Request) {
 				_, _ = w.Write([]byte("hello, world!"))
 			})
+			rt.HandleFunc(http.MethodGet, "/following", followingHandlerFn(crawler))
+			rt.HandleFunc(http.MethodGet, "/followers", followersHandlerFn(crawler))
+			rt.HandleFunc(http.MethodGet, "/tweets/{id}/replies", repliesHandlerFn(crawler))
+			rt.HandleFunc(http.MethodGet, "/tweets/{id}/quotes", quotesHandlerFn(crawler))
+			rt.HandleFunc(http.MethodGet, "/tweets/{id}/retweets", retweetsHandlerFn(crawler))
+			rt.HandleFunc(http.MethodGet, "/tweets/{id}/likes", likesHandlerFn(crawler))
+			rt.HandleFunc(http.MethodGet, "/tweets/{id}", tweetHandlerFn(crawler))
+			rt.HandleFunc(http.MethodGet, "/users/{screen_name}/statuses", statusesHandlerFn(crawler))
+			// after /users/{screen_name}/statuses, "id" and "lookup" being valid screen names
+			rt.HandleFunc(http.MethodPost, "/users/lookup", usersLookupHandlerFn(crawler))
+			rt.HandleFunc(http.MethodGet, "/users/id/{id}", userByIDHandlerFn(crawler))
+			rt.HandleFunc(http.MethodGet, "/users/{screen_name}", userHandlerFn(crawler))
+
+			adminToken := c.String("admin-token")
+			if adminToken == "" {
+				logger.Warn("admin endpoints and metrics are disabled, set --admin-token to enable them")
+			}
+			rt.HandleFunc(http.MethodGet, "/admin/queues", adminOnly(adminToken, queuesHandlerFn(crawler)))
+			rt.HandleFunc(http.MethodGet, "/admin/accounts", adminOnly(adminToken, accountsHandlerFn(crawler)))
+			rt.HandleFunc(http.MethodPost, "/admin/accounts", adminOnly(adminToken, addAccountHandlerFn(crawler)))
+			rt.HandleFunc(http.MethodPost, "/admin/accounts/{username}/relogin", adminOnly(adminToken, reloginAccountHandlerFn(crawler)))
+			rt.HandleFunc(http.MethodPost, "/admin/accounts/{username}/disable", adminOnly(adminToken, disableAccountHandlerFn(crawler, true)))
+			rt.HandleFunc(http.MethodPost, "/admin/accounts/{username}/enable", adminOnly(adminToken, disableAccountHandlerFn(crawler, false)))
+			rt.HandleFunc(http.MethodPost, "/admin/accounts/{username}/clear-forbidden", adminOnly(adminToken, clearForbiddenHandlerFn(crawler)))
+			rt.HandleFunc(http.MethodDelete, "/admin/accounts/{username}", adminOnly(adminToken, removeAccountHandlerFn(crawler)))
+			// account names are labels, so metrics are guarded like the admin endpoints
+			rt.HandleFunc(http.MethodGet, "/metrics", adminOnly(adminToken, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP))
 
 			addr := c.String("addr")
-			log.Printf("starting server on %s\n", addr)
-			if err := http.ListenAndServe(addr, nil); err != http.ErrServerClosed {
+			srv := &http.Server{
+				Addr:        addr,
+				Handler:     withRequestLogging(rt),
+				BaseContext: func(net.Listener) context.Context { return baseCtx },
+			}
+
+			ln, err := net.Listen("tcp", addr)
+			if err != nil {
+				_ = crawler.Close()
 				return fmt.Errorf("failed to start server: %v", err)
 			}
+			logger.Info("starting server", "addr", addr)
 
-			return nil
-		},
-	}
-}
+			sigCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+			// a second signal kills the process
+			context.AfterFunc(sigCtx, stop)
 
-func followingHandlerFn(crawler *twitter.Crawler) func(w http.ResponseWriter, r *http.Request) {
-	return func(w http.ResponseWriter, r *http.Request) {
-		twitterUserID := r.URL.Query().Get("id")
-		followingIDs, err := crawlFollowing(r.Context(), crawler, twitterUserID)
-		respJSON(w, followingIDs, err)
+			return serve(sigCtx, srv, ln, crawler, cancelBase, c.Duration("shutdown-timeout"))
+		},
 	}
 }
 
-type Following struct {
-	ID       string `json:"id"`
-	Username string `json:"username"`
-	Name     string `json:"name"`
-}
+// serve serves srv on ln until ctx is done, then shuts it down: active requests are given timeout to finish
+// before cancelBase, which cancels the base context of srv, aborts them. The crawler is closed last.
+func serve(ctx context.Context, srv *http.Server, ln net.Listener, crawler *twitter.Crawler, cancelBase context.CancelFunc, timeout time.Duration) error {
+	srvErr := make(chan error, 1)
+	go func() {
+		srvErr <- srv.Serve(ln)
+	}()
 
-func crawlFollowing(ctx context.Context, crawler *twitter.Crawler, userID string) ([]Following, error) {
-	if userID == "" {
-		return nil, fmt.Errorf("invalid user id")
+	select {
+	case err := <-srvErr:
+		cancelBase()
+		_ = crawler.Close()
+		return fmt.Errorf("server failed: %v", err)
+	case <-ctx.Done():
 	}
 
-	cursor := ""
-	results := make([]Following, 0)
-	for {
-		var items []twitter.Following
-		var err error
-
-		items, cursor, err = crawler.Following(ctx, userID, cursor)
-		if err != nil {
-			return nil, err
-		}
+	slog.Info("shutting down, waiting for active requests", "timeout", timeout)
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), timeout)
+	defer cancelShutdown()
 
-		for _, item := range items {
-			results = append(results, Following{
-				ID:       item.UserID,
-				Username: item.ScreenName,
-				Name:     item.Name,
-			})
-		}
+	err := srv.Shutdown(shutdownCtx)
+	if err != nil {
+		slog.Warn("active requests did not finish in time, cancelling them", "error", err)
+		_ = srv.Close()
+	}
+	// aborts the crawls left, if any, and the credentials watcher
+	cancelBase()
 
-		if cursor == "" {
-			break
-		}
+	err = crawler.Close()
+	if err != nil {
+		slog.Warn("failed to close crawler", "error", err)
 	}
 
-	return results, nil
+	slog.Info("server stopped")
+	return nil
 }
 
 func respJSON(w http.ResponseWriter, data interface{}, err error) {
-	type resp struct {
-		Code    int         `json:"code"`
-		Data    interface{} `json:"data"`
-		Message string      `json:"message"`
-	}
+	respJSONPage(w, data, "", err)
+}
 
+func respJSONPage(w http.ResponseWriter, data interface{}, nextCursor string, err error) {
 	var r resp
+	statusCode := http.StatusOK
 	if err == nil {
 		r = resp{
-			Code: 0,
-			Data: data,
+			Code:       0,
+			Data:       data,
+			NextCursor: nextCursor,
 		}
 	} else {
+		var errCode string
+		statusCode, errCode = classifyError(err)
 		r = resp{
 			Code:    -1,
 			Message: err.Error(),
+			Error:   errCode,
 		}
+		setRetryAfter(w, err)
 	}
 
+	writeResp(w, statusCode, r)
+}
+
+// respJSONPartial answers with the items crawled before err, along with the cursor to resume from
+// and the class of err, so callers can tell a truncated page from a short one.
+func respJSONPartial(w http.ResponseWriter, data interface{}, nextCursor string, err error) {
+	_, errCode := classifyError(err)
+	setRetryAfter(w, err)
+
+	writeResp(w, http.StatusOK, resp{
+		Code:       0,
+		Data:       data,
+		NextCursor: nextCursor,
+		Partial:    true,
+		Message:    err.Error(),
+		Error:      errCode,
+	})
+}
+
+type resp struct {
+	Code       int         `json:"code"`
+	Data       interface{} `json:"data"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	Partial    bool        `json:"partial,omitempty"`
+	Message    string      `json:"message"`
+	Error      string      `json:"error,omitempty"`
+}
+
+func writeResp(w http.ResponseWriter, statusCode int, r resp) {
 	rBz, _ := json.Marshal(r)
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(statusCode)
 	_, _ = w.Write(rBz)
 }
+
+// defaultRetryAfter is sent when Twitter rate limits a request without saying when its window resets.
+const defaultRetryAfter = time.Minute
+
+// setRetryAfter tells when to retry if err is caused by rate limiting.
+func setRetryAfter(w http.ResponseWriter, err error) {
+	if !errors.Is(err, twitter.ErrRateLimited) {
+		return
+	}
+
+	retryAfter := defaultRetryAfter
+	var rateLimitErr *twitter.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		retryAfter = time.Until(time.Unix(rateLimitErr.Reset, 0))
+	}
+	if retryAfter < time.Second {
+		retryAfter = time.Second
+	}
+	w.Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(retryAfter.Seconds())), 10))
+}
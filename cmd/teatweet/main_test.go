@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// startServe serves handler with serve and returns the address it listens on, the function stopping it and
+// the result of serve.
+func startServe(t *testing.T, handler http.HandlerFunc, timeout time.Duration) (string, context.CancelFunc, <-chan error) {
+	crawler, _ := newFakeCrawler(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+	srv := &http.Server{
+		Handler:     handler,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	t.Cleanup(stop)
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(ctx, srv, ln, crawler, cancelBase, timeout)
+	}()
+
+	return "http://" + ln.Addr().String(), stop, served
+}
+
+func TestServeShutdown(t *testing.T) {
+	// active requests are waited for
+	started, release := make(chan struct{}), make(chan struct{})
+	addr, stop, served := startServe(t, func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		_, _ = w.Write([]byte("ok"))
+	}, time.Minute)
+
+	type result struct {
+		body string
+		err  error
+	}
+	get := func(addr string) <-chan result {
+		results := make(chan result, 1)
+		go func() {
+			resp, err := http.Get(addr)
+			if err != nil {
+				results <- result{err: err}
+				return
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			results <- result{body: string(body), err: err}
+		}()
+		return results
+	}
+
+	results := get(addr)
+	<-started
+	stop()
+	select {
+	case <-served:
+		t.Fatal("server stopped before the active request finished")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	res := <-results
+	assert.NoError(t, res.err)
+	assert.Equal(t, "ok", res.body)
+	assert.NoError(t, <-served)
+
+	// past the timeout, they are cancelled
+	started, canceled := make(chan struct{}), make(chan struct{})
+	addr, stop, served = startServe(t, func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+		close(canceled)
+	}, 50*time.Millisecond)
+
+	results = get(addr)
+	<-started
+	stop()
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("active request was not cancelled after the timeout")
+	}
+	assert.NoError(t, <-served)
+	assert.Error(t, (<-results).err)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+type pathParamsKey struct{}
+
+type route struct {
+	method   string
+	segments []string
+	handler  http.HandlerFunc
+}
+
+// router is a tiny path router supporting {name} placeholders, e.g. /tweets/{id}/replies.
+// Routes are matched in registration order.
+type router struct {
+	routes   []route
+	notFound http.HandlerFunc
+}
+
+func newRouter(notFound http.HandlerFunc) *router {
+	if notFound == nil {
+		notFound = http.NotFound
+	}
+
+	return &router{notFound: notFound}
+}
+
+func (rt *router) HandleFunc(method string, pattern string, handler http.HandlerFunc) {
+	rt.routes = append(rt.routes, route{
+		method:   method,
+		segments: splitPath(pattern),
+		handler:  handler,
+	})
+}
+
+func (rt *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	segments := splitPath(r.URL.Path)
+	methodNotAllowed := false
+
+	for _, rr := range rt.routes {
+		params, ok := matchSegments(rr.segments, segments)
+		if !ok {
+			continue
+		}
+
+		if rr.method != "" && rr.method != r.Method {
+			methodNotAllowed = true
+			continue
+		}
+
+		if len(params) > 0 {
+			r = r.WithContext(context.WithValue(r.Context(), pathParamsKey{}, params))
+		}
+		rr.handler(w, r)
+		return
+	}
+
+	if methodNotAllowed {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	rt.notFound(w, r)
+}
+
+// pathParam returns the value of the {name} placeholder matched for r.
+func pathParam(r *http.Request, name string) string {
+	params, _ := r.Context().Value(pathParamsKey{}).(map[string]string)
+	return params[name]
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+
+	return strings.Split(path, "/")
+}
+
+func matchSegments(pattern []string, segments []string) (map[string]string, bool) {
+	if len(pattern) != len(segments) {
+		return nil, false
+	}
+
+	var params map[string]string
+	for i, p := range pattern {
+		if strings.HasPrefix(p, "{") && strings.HasSuffix(p, "}") {
+			if segments[i] == "" {
+				return nil, false
+			}
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[p[1:len(p)-1]] = segments[i]
+			continue
+		}
+
+		if p != segments[i] {
+			return nil, false
+		}
+	}
+
+	return params, true
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouter(t *testing.T) {
+	rt := newRouter(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	handler := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(name + " " + pathParam(r, "id") + pathParam(r, "screen_name")))
+		}
+	}
+	rt.HandleFunc(http.MethodGet, "/tweets/{id}/likes", handler("likes"))
+	rt.HandleFunc(http.MethodGet, "/tweets/{id}", handler("tweet"))
+	rt.HandleFunc(http.MethodPost, "/users/lookup", handler("lookup"))
+	rt.HandleFunc(http.MethodGet, "/users/{screen_name}", handler("user"))
+
+	tests := []struct {
+		method     string
+		path       string
+		statusCode int
+		body       string
+	}{
+		{http.MethodGet, "/tweets/100/likes", http.StatusOK, "likes 100"},
+		{http.MethodGet, "/tweets/100/likes/", http.StatusOK, "likes 100"},
+		{http.MethodGet, "/tweets/100", http.StatusOK, "tweet 100"},
+		{http.MethodGet, "/tweets//likes", http.StatusTeapot, ""},
+		{http.MethodGet, "/tweets/100/quotes", http.StatusTeapot, ""},
+		// routes are matched in registration order
+		{http.MethodPost, "/users/lookup", http.StatusOK, "lookup "},
+		{http.MethodGet, "/users/lookup", http.StatusOK, "user lookup"},
+		{http.MethodDelete, "/tweets/100", http.StatusMethodNotAllowed, "Method Not Allowed\n"},
+		{http.MethodGet, "/", http.StatusTeapot, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.method+" "+test.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			rt.ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+			assert.Equal(t, test.statusCode, w.Code)
+			assert.Equal(t, test.body, w.Body.String())
+		})
+	}
+}
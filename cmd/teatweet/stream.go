@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	contentTypeNDJSON      = "application/x-ndjson"
+	contentTypeEventStream = "text/event-stream"
+
+	streamEventPage = "page"
+	streamEventDone = "done"
+)
+
+// streamEvent is a single message of a streaming response.
+// "page" events carry crawled items and the cursor following them,
+// the last event is always "done" and carries the cursor to resume from and the error, if any.
+type streamEvent struct {
+	Event      string      `json:"event"`
+	Code       int         `json:"code"`
+	Data       interface{} `json:"data,omitempty"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	Message    string      `json:"message,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// streamWriter writes events either as newline delimited JSON or as Server-Sent Events.
+type streamWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	sse     bool
+}
+
+func isStreamRequest(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, contentTypeEventStream) || strings.Contains(accept, contentTypeNDJSON)
+}
+
+// newStreamWriter returns a streamWriter if the request accepts a streaming content type.
+func newStreamWriter(w http.ResponseWriter, r *http.Request) (*streamWriter, bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, false
+	}
+
+	accept := r.Header.Get("Accept")
+	switch {
+	case strings.Contains(accept, contentTypeEventStream):
+		return &streamWriter{w: w, flusher: flusher, sse: true}, true
+	case strings.Contains(accept, contentTypeNDJSON):
+		return &streamWriter{w: w, flusher: flusher, sse: false}, true
+	default:
+		return nil, false
+	}
+}
+
+func (sw *streamWriter) writeHeader() {
+	if sw.sse {
+		sw.w.Header().Set("Content-Type", contentTypeEventStream)
+		sw.w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		sw.w.Header().Set("Content-Type", contentTypeNDJSON)
+	}
+	// disable proxy buffering (nginx)
+	sw.w.Header().Set("X-Accel-Buffering", "no")
+	sw.w.WriteHeader(http.StatusOK)
+	sw.flusher.Flush()
+}
+
+func (sw *streamWriter) writeEvent(event streamEvent) error {
+	eventBz, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if sw.sse {
+		_, err = fmt.Fprintf(sw.w, "event: %s\ndata: %s\n\n", event.Event, eventBz)
+	} else {
+		_, err = fmt.Fprintf(sw.w, "%s\n", eventBz)
+	}
+	if err != nil {
+		return err
+	}
+
+	sw.flusher.Flush()
+	return nil
+}
+
+// streamCrawl writes every crawled page as soon as it is available, followed by a final "done" event.
+func streamCrawl[T any, R any](ctx context.Context, sw *streamWriter, fetch fetchFn[T], convert func(T) R, cursor string, limit int) {
+	sw.writeHeader()
+
+	var writeErr error
+	nextCursor, err := crawlEach(ctx, fetch, cursor, limit, func(items []T, nextCursor string) error {
+		results := make([]R, 0, len(items))
+		for _, item := range items {
+			results = append(results, convert(item))
+		}
+
+		writeErr = sw.writeEvent(streamEvent{
+			Event:      streamEventPage,
+			Data:       results,
+			NextCursor: nextCursor,
+		})
+		return writeErr
+	})
+	if writeErr != nil {
+		// client has gone away
+		return
+	}
+
+	done := streamEvent{
+		Event:      streamEventDone,
+		NextCursor: nextCursor,
+	}
+	if err != nil {
+		requestLogger(ctx).Warn("crawl failed", "cursor", nextCursor, "error", err)
+		done.Code = -1
+		done.Message = err.Error()
+		_, done.Error = classifyError(err)
+	}
+	_ = sw.writeEvent(done)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hiendaovinh/toolkit/pkg/arr"
+	"github.com/stretchr/testify/assert"
+)
+
+// streamLikes streams the likes of a tweet with accept as content type.
+func streamLikes(handler http.HandlerFunc, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/tweets/100/likes", nil)
+	req.Header.Set("Accept", accept)
+	return serveRoute(handler, "/tweets/{id}/likes", req)
+}
+
+func parseNDJSON(t *testing.T, body string) []streamEvent {
+	events := make([]streamEvent, 0)
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		var event streamEvent
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		events = append(events, event)
+	}
+
+	return events
+}
+
+func TestStreamCrawl(t *testing.T) {
+	crawler, fake := newFakeCrawler(t)
+	handler := likesHandlerFn(crawler)
+
+	// every page is written as it is crawled, followed by a final event
+	w := streamLikes(handler, contentTypeNDJSON)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, contentTypeNDJSON, w.Header().Get("Content-Type"))
+	events := parseNDJSON(t, w.Body.String())
+	eventNames := func(events []streamEvent) []string {
+		return arr.ArrMap(events, func(event streamEvent) string { return event.Event })
+	}
+	assert.Equal(t, []string{streamEventPage, streamEventPage, streamEventDone}, eventNames(events))
+	assert.Len(t, events[0].Data, 2)
+	assert.Equal(t, "favoriters-page-2", events[0].NextCursor)
+	assert.Equal(t, streamEvent{Event: streamEventDone}, events[2])
+
+	w = streamLikes(handler, contentTypeEventStream)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, contentTypeEventStream, w.Header().Get("Content-Type"))
+	assert.True(t, strings.HasPrefix(w.Body.String(), "event: page\ndata: {"))
+	assert.True(t, strings.HasSuffix(w.Body.String(), "event: done\ndata: {\"event\":\"done\",\"code\":0}\n\n"))
+
+	// a failure ends the stream with the cursor to resume from and the class of the error
+	fake.SetRateLimit("Favoriters", 1, time.Now().Add(time.Minute))
+	w = streamLikes(handler, contentTypeNDJSON)
+	assert.Equal(t, http.StatusOK, w.Code)
+	events = parseNDJSON(t, w.Body.String())
+	assert.Equal(t, []string{streamEventPage, streamEventDone}, eventNames(events))
+	assert.Equal(t, -1, events[1].Code)
+	assert.Equal(t, "favoriters-page-2", events[1].NextCursor)
+	assert.Equal(t, errCodeRateLimited, events[1].Error)
+}
@@ -0,0 +1,164 @@
+package twitter
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Connection states of an account.
+const (
+	AccountConnected    = "connected"
+	AccountReconnecting = "reconnecting"
+	AccountDisconnected = "disconnected"
+)
+
+// AccountStatus is a snapshot of an account's client for one API call.
+type AccountStatus struct {
+	Username  string `json:"username"`
+	State     string `json:"state"`
+	Disabled  bool   `json:"disabled"`
+	Forbidden bool   `json:"forbidden"`
+	// ProbeAttempts is the number of failed probes since the client was forbidden.
+	ProbeAttempts int       `json:"probe_attempts"`
+	Pending       int64     `json:"pending"`
+	Remaining     int64     `json:"remaining"`
+	Reset         int64     `json:"reset"`
+	LastError     string    `json:"last_error,omitempty"`
+	LastErrorAt   time.Time `json:"last_error_at"`
+	LastLoginAt   time.Time `json:"last_login_at"`
+	// Reconnect is the state of the account's reconnect loop, shared by the clients of every API call.
+	Reconnect ReconnectStatus `json:"reconnect"`
+}
+
+func (client *Client) status() AccountStatus {
+	var reconnect ReconnectStatus
+	if client.reconnector != nil {
+		reconnect = client.reconnector.status()
+	}
+
+	state := AccountDisconnected
+	switch {
+	case client.baseClient.Connected():
+		state = AccountConnected
+	case client.baseClient.LoggingIn(), reconnect.Running:
+		state = AccountReconnecting
+	}
+
+	client.mtx.Lock()
+	defer client.mtx.Unlock()
+
+	return AccountStatus{
+		Username:      client.baseClient.Username,
+		State:         state,
+		Disabled:      client.disabled,
+		Forbidden:     client.forbidden,
+		ProbeAttempts: client.probeAttempts,
+		Pending:       client.pending,
+		Remaining:     client.remaining,
+		Reset:         client.reset,
+		LastError:     client.lastError,
+		LastErrorAt:   client.lastErrorAt,
+		LastLoginAt:   client.baseClient.LastLoginAt(),
+		Reconnect:     reconnect,
+	}
+}
+
+// Accounts returns the status of every account, per API call.
+func (crawler *Crawler) Accounts() map[string][]AccountStatus {
+	accounts := make(map[string][]AccountStatus, len(apis))
+	for apiName := range apis {
+		statuses := make([]AccountStatus, 0)
+		for _, client := range crawler.apiClients(apiName) {
+			statuses = append(statuses, client.status())
+		}
+		sort.Slice(statuses, func(i, j int) bool { return statuses[i].Username < statuses[j].Username })
+		accounts[apiName] = statuses
+	}
+
+	return accounts
+}
+
+// Relogin forces an account to log in again, e.g. after its password has been reset.
+func (crawler *Crawler) Relogin(ctx context.Context, username string) error {
+	clients, err := crawler.accountClients(username)
+	if err != nil {
+		return err
+	}
+
+	var reconnector *reconnector
+	for _, client := range clients {
+		reconnector = client.reconnector
+		break
+	}
+	if !reconnector.baseClient.CanReconnect() {
+		return fmt.Errorf("account (%s) cannot log in: %w: missing credential", username, ErrUnauthorized)
+	}
+
+	return reconnector.relogin(ctx)
+}
+
+// SetAccountDisabled takes an account out of rotation, or puts it back. It stays in the pool either way.
+func (crawler *Crawler) SetAccountDisabled(username string, disabled bool) error {
+	clients, err := crawler.accountClients(username)
+	if err != nil {
+		return err
+	}
+
+	for _, client := range clients {
+		client.mtx.Lock()
+		client.disabled = disabled
+		client.mtx.Unlock()
+		client.capacity.broadcast()
+	}
+
+	return nil
+}
+
+// ClearForbidden puts an account which was forbidden by Twitter back in rotation.
+// If call is empty, it is cleared for every API call.
+func (crawler *Crawler) ClearForbidden(username string, call string) error {
+	if _, ok := apis[call]; call != "" && !ok {
+		return fmt.Errorf("unknown api call: %s", call)
+	}
+
+	clients, err := crawler.accountClients(username)
+	if err != nil {
+		return err
+	}
+
+	for apiName, client := range clients {
+		if call != "" && apiName != call {
+			continue
+		}
+
+		client.mtx.Lock()
+		client.forbidden = false
+		client.probeAttempts = 0
+		client.nextProbeAt = time.Time{}
+		client.mtx.Unlock()
+		client.capacity.broadcast()
+	}
+
+	return nil
+}
+
+// accountClients returns the clients of an account, per API call it is in the pool of.
+func (crawler *Crawler) accountClients(username string) (map[string]*Client, error) {
+	crawler.mtx.RLock()
+	defer crawler.mtx.RUnlock()
+
+	clients := make(map[string]*Client, len(crawler.clients))
+	for apiName, apiClients := range crawler.clients {
+		if client, ok := apiClients[username]; ok {
+			clients[apiName] = client
+		}
+	}
+
+	if len(clients) == 0 {
+		return nil, ErrAccountNotFound
+	}
+
+	return clients, nil
+}
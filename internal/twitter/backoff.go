@@ -0,0 +1,36 @@
+package twitter
+
+import (
+	"math/rand"
+	"time"
+)
+
+// BackoffPolicy controls how often a failing operation is retried.
+type BackoffPolicy struct {
+	// Min is the delay before the first retry, it doubles after every failed attempt up to Max.
+	Min time.Duration
+	Max time.Duration
+
+	// MaxAttempts is the number of attempts before giving up, 0 to never give up.
+	MaxAttempts int
+}
+
+// delay returns the jittered delay before the given retry, counting from 0.
+// The delay is at least half of the exponential backoff, so that retries of many clients do not line up.
+func (policy BackoffPolicy) delay(attempt int) time.Duration {
+	d := policy.Max
+	if attempt < 32 && policy.Min<<attempt < policy.Max && policy.Min<<attempt > 0 {
+		d = policy.Min << attempt
+	}
+
+	if d <= 1 {
+		return d
+	}
+
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)))
+}
+
+// exhausted reports whether no attempt is left after the given number of attempts.
+func (policy BackoffPolicy) exhausted(attempts int) bool {
+	return policy.MaxAttempts > 0 && attempts >= policy.MaxAttempts
+}
@@ -6,9 +6,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/http/cookiejar"
+	"net/url"
 	"regexp"
 	"sync"
 	"time"
@@ -17,28 +19,37 @@ import (
 type Credential struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+
+	// Email and Phone answer the "enter your phone number or email" challenge of logins from a new IP.
+	Email string `json:"email,omitempty"`
+	Phone string `json:"phone,omitempty"`
+
+	// TOTPSecret is the base32 secret of the authenticator app, required if 2FA is enabled.
+	TOTPSecret string `json:"totp_secret,omitempty"`
+
+	// Cookies of an account that cannot log in with its password.
+	// Unless a password is given too, such accounts cannot reconnect.
+	Cookies Cookies `json:"cookies,omitempty"`
 }
 
 type BaseClient struct {
 	Credential
+	hosts      Hosts
 	httpClient *http.Client
 	mtx        *sync.RWMutex
 
 	connected    bool
-	lastSyncedAt time.Time
+	loggingIn    bool
+	lastSyncedAt time.Time     // of the cookies, by a login or a restored session
+	lastLoginAt  time.Time     // of the last successful login flow
 	rMtx         *sync.RWMutex // another lock, but it is not strict so that we can leave early without waiting for the main lock
+
+	sessionStore SessionStore // optional, the cookie jar is saved after every login
+	logger       *slog.Logger
 }
 
 func NewBaseClientFromRawCookies(ctx context.Context, username string, rawCookies string) (*BaseClient, error) {
-	header := http.Header{}
-	header.Add("Cookie", rawCookies)
-	req := http.Request{Header: header}
-	cookies := req.Cookies()
-	for _, c := range cookies {
-		c.Path = "/"
-		c.Domain = ".twitter.com"
-	}
-
+	cookies := normalizeCookies(parseRawCookies(rawCookies))
 	return NewBaseClientFromCookies(ctx, username, cookies)
 }
 
@@ -48,39 +59,30 @@ func NewBaseClientFromCookies(_ context.Context, username string, cookies []*htt
 		return nil, err
 	}
 
-	jar.SetCookies(twitterURL, cookies)
-
-	return &BaseClient{
+	baseClient := &BaseClient{
 		Credential: Credential{
 			Username: username,
 			Password: "",
 		},
+		hosts:      DefaultHosts,
 		httpClient: &http.Client{Jar: jar},
 		mtx:        &sync.RWMutex{},
 
 		connected: true,
 		rMtx:      &sync.RWMutex{},
-	}, nil
+		logger:    slog.Default().With("account", username),
+	}
+	baseClient.setCookies(cookies)
+
+	return baseClient, nil
 }
 
 func NewBaseClientFromPassword(ctx context.Context, username string, password string) (*BaseClient, error) {
-	jar, err := cookiejar.New(nil)
+	baseClient, err := newBaseClient(Credential{Username: username, Password: password})
 	if err != nil {
 		return nil, err
 	}
 
-	baseClient := &BaseClient{
-		Credential: Credential{
-			Username: username,
-			Password: password,
-		},
-		httpClient: &http.Client{Jar: jar},
-		mtx:        &sync.RWMutex{},
-
-		connected: false,
-		rMtx:      &sync.RWMutex{},
-	}
-
 	err = baseClient.Login(ctx)
 	if err != nil {
 		return nil, err
@@ -89,13 +91,32 @@ func NewBaseClientFromPassword(ctx context.Context, username string, password st
 	return baseClient, nil
 }
 
+// newBaseClient returns a base client which is not logged in yet.
+func newBaseClient(credential Credential) (*BaseClient, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BaseClient{
+		Credential: credential,
+		hosts:      DefaultHosts,
+		httpClient: &http.Client{Jar: jar},
+		mtx:        &sync.RWMutex{},
+
+		connected: false,
+		rMtx:      &sync.RWMutex{},
+		logger:    slog.Default().With("account", credential.Username),
+	}, nil
+}
+
 func (baseClient *BaseClient) Login(ctx context.Context) error {
 	// this mutex ensure all pending
 	baseClient.mtx.Lock()
 	defer baseClient.mtx.Unlock()
 
 	if baseClient.Password == "" {
-		return fmt.Errorf("failed to login: missing credential")
+		return fmt.Errorf("failed to login: %w: missing credential", ErrUnauthorized)
 	}
 
 	jar, err := cookiejar.New(nil)
@@ -105,9 +126,15 @@ func (baseClient *BaseClient) Login(ctx context.Context) error {
 
 	baseClient.rMtx.Lock()
 	baseClient.connected = false
+	baseClient.loggingIn = true
 	baseClient.rMtx.Unlock()
+	defer func() {
+		baseClient.rMtx.Lock()
+		baseClient.loggingIn = false
+		baseClient.rMtx.Unlock()
+	}()
 
-	baseClient.httpClient = &http.Client{Jar: jar}
+	baseClient.httpClient = &http.Client{Jar: jar, Transport: baseClient.httpClient.Transport}
 
 	err = baseClient.initGuessToken(ctx)
 	if err != nil {
@@ -117,39 +144,25 @@ func (baseClient *BaseClient) Login(ctx context.Context) error {
 		}
 	}
 
-	flowToken, err := baseClient.startLoginFlow(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to start login flow: %s", err)
-	}
-
-	flowToken, err = baseClient.loginJsInstrumentationSubtask(ctx, flowToken)
-	if err != nil {
-		return fmt.Errorf("LoginEnterUserIdentifierSSO failed: %s", err)
-	}
-
-	flowToken, err = baseClient.loginEnterUserIdentifierSSO(ctx, flowToken, baseClient.Username)
-	if err != nil {
-		return fmt.Errorf("LoginEnterUserIdentifierSSO failed: %s", err)
-	}
-
-	flowToken, err = baseClient.loginEnterPassword(ctx, flowToken, baseClient.Password)
+	err = baseClient.runLoginFlow(ctx)
 	if err != nil {
-		return fmt.Errorf("LoginEnterPassword failed: %s", err)
+		return err
 	}
 
-	_, err = baseClient.accountDuplicationCheck(ctx, flowToken)
+	err = baseClient.ensureSearchSafety(ctx)
 	if err != nil {
-		return fmt.Errorf("AccountDuplicationCheck failed: %s", err)
+		baseClient.logger.Warn("cannot enable search safety, however it is enabled by default anyway", "error", err)
 	}
 
-	err = baseClient.ensureSearchSafety(ctx)
+	err = baseClient.saveSession()
 	if err != nil {
-		log.Printf("[WARN] (%s) cannot enable search safety, however it is enabled by default anyway: %s", baseClient.Username, err)
+		baseClient.logger.Warn("cannot save session", "error", err)
 	}
 
 	baseClient.rMtx.Lock()
 	baseClient.connected = true
 	baseClient.lastSyncedAt = time.Now()
+	baseClient.lastLoginAt = baseClient.lastSyncedAt
 	baseClient.rMtx.Unlock()
 
 	return nil
@@ -193,6 +206,14 @@ func (baseClient *BaseClient) Connected() bool {
 	return baseClient.connected
 }
 
+// LoggingIn reports whether a login is in progress.
+func (baseClient *BaseClient) LoggingIn() bool {
+	baseClient.rMtx.RLock()
+	defer baseClient.rMtx.RUnlock()
+
+	return baseClient.loggingIn
+}
+
 func (baseClient *BaseClient) Disconnect() {
 	baseClient.rMtx.Lock()
 	defer baseClient.rMtx.Unlock()
@@ -207,8 +228,15 @@ func (baseClient *BaseClient) LastSyncedAt() time.Time {
 	return baseClient.lastSyncedAt
 }
 
+func (baseClient *BaseClient) LastLoginAt() time.Time {
+	baseClient.rMtx.RLock()
+	defer baseClient.rMtx.RUnlock()
+
+	return baseClient.lastLoginAt
+}
+
 func (baseClient *BaseClient) initGuessToken(ctx context.Context) error {
-	req, err := http.NewRequest(http.MethodGet, "https://twitter.com/", nil)
+	req, err := http.NewRequest(http.MethodGet, baseClient.hosts.Web+"/", nil)
 	if err != nil {
 		return err
 	}
@@ -221,7 +249,7 @@ func (baseClient *BaseClient) initGuessToken(ctx context.Context) error {
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected response code %d", resp.StatusCode)
+		return &StatusError{StatusCode: resp.StatusCode}
 	}
 
 	bodyBz, err := io.ReadAll(resp.Body)
@@ -239,7 +267,7 @@ func (baseClient *BaseClient) initGuessToken(ctx context.Context) error {
 		Name:   "gt",
 		Value:  string(matches[1]),
 		Path:   "/",
-		Domain: ".twitter.com",
+		Domain: baseClient.cookieDomain(),
 		MaxAge: 10800,
 		Secure: true,
 	})
@@ -249,7 +277,7 @@ func (baseClient *BaseClient) initGuessToken(ctx context.Context) error {
 }
 
 func (baseClient *BaseClient) initGuessToken2(ctx context.Context) error {
-	req, err := http.NewRequest(http.MethodPost, "https://api.twitter.com/1.1/guest/activate.json", nil)
+	req, err := http.NewRequest(http.MethodPost, baseClient.hosts.API+"/1.1/guest/activate.json", nil)
 	if err != nil {
 		return err
 	}
@@ -264,7 +292,7 @@ func (baseClient *BaseClient) initGuessToken2(ctx context.Context) error {
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected response code %d", resp.StatusCode)
+		return &StatusError{StatusCode: resp.StatusCode}
 	}
 
 	bodyBz, err := io.ReadAll(resp.Body)
@@ -285,7 +313,7 @@ func (baseClient *BaseClient) initGuessToken2(ctx context.Context) error {
 		Name:   "gt",
 		Value:  respBody.GuestToken,
 		Path:   "/",
-		Domain: ".twitter.com",
+		Domain: baseClient.cookieDomain(),
 		MaxAge: 10800,
 		Secure: true,
 	})
@@ -294,276 +322,9 @@ func (baseClient *BaseClient) initGuessToken2(ctx context.Context) error {
 	return nil
 }
 
-func (baseClient *BaseClient) startLoginFlow(ctx context.Context) (string, error) {
-	reqBodyBz := []byte(`{"input_flow_data":{"flow_context":{"debug_overrides":{},"start_location":{"location":"unknown"}}},"subtask_versions":{"action_list":2,"alert_dialog":1,"app_download_cta":1,"check_logged_in_account":1,"choice_selection":3,"contacts_live_sync_permission_prompt":0,"cta":7,"email_verification":2,"end_flow":1,"enter_date":1,"enter_email":2,"enter_password":5,"enter_phone":2,"enter_recaptcha":1,"enter_text":5,"enter_username":2,"generic_urt":3,"in_app_notification":1,"interest_picker":3,"js_instrumentation":1,"menu_dialog":1,"notifications_permission_prompt":2,"open_account":2,"open_home_timeline":1,"open_link":1,"phone_verification":4,"privacy_options":1,"security_key":3,"select_avatar":4,"select_banner":2,"settings_list":7,"show_code":1,"sign_up":2,"sign_up_review":4,"tweet_selection_urt":1,"update_users":1,"upload_media":1,"user_recommendations_list":4,"user_recommendations_urt":1,"wait_spinner":3,"web_modal":1}}`)
-	req, err := http.NewRequest(http.MethodPost, "https://api.twitter.com/1.1/onboarding/task.json?flow_name=login", bytes.NewReader(reqBodyBz))
-	if err != nil {
-		return "", err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", defaultBearerToken))
-	cookies := baseClient.httpClient.Jar.Cookies(req.URL)
-	for _, c := range cookies {
-		if c.Name == "gt" {
-			req.Header.Set("X-Guest-Token", c.Value)
-			break
-		}
-	}
-	req = req.WithContext(ctx)
-
-	resp, err := baseClient.httpClient.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected response code %d", resp.StatusCode)
-	}
-
-	bodyBz, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
-
-	var respBody struct {
-		FlowToken string `json:"flow_token"`
-	}
-	err = json.Unmarshal(bodyBz, &respBody)
-	if err != nil {
-		return "", err
-	}
-
-	return respBody.FlowToken, nil
-}
-
-func (baseClient *BaseClient) loginJsInstrumentationSubtask(ctx context.Context, flowToken string) (string, error) {
-	reqBodyBz, _ := json.Marshal(map[string]interface{}{
-		"flow_token": flowToken,
-		"subtask_inputs": []map[string]interface{}{
-			{
-				"subtask_id": "LoginJsInstrumentationSubtask",
-				"js_instrumentation": map[string]interface{}{
-					"response": "{}",
-					"link":     "next_link",
-				},
-			},
-		},
-	})
-	req, err := http.NewRequest(http.MethodPost, "https://api.twitter.com/1.1/onboarding/task.json", bytes.NewReader(reqBodyBz))
-	if err != nil {
-		return "", err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	cookies := baseClient.httpClient.Jar.Cookies(req.URL)
-	for _, c := range cookies {
-		if c.Name == "gt" {
-			req.Header.Set("X-Guest-Token", c.Value)
-			break
-		}
-	}
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", defaultBearerToken))
-	req = req.WithContext(ctx)
-
-	resp, err := baseClient.httpClient.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected response code %d", resp.StatusCode)
-	}
-
-	bodyBz, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
-
-	var respBody struct {
-		FlowToken string `json:"flow_token"`
-	}
-	err = json.Unmarshal(bodyBz, &respBody)
-	if err != nil {
-		return "", err
-	}
-
-	return respBody.FlowToken, nil
-}
-
-func (baseClient *BaseClient) loginEnterUserIdentifierSSO(ctx context.Context, flowToken string, username string) (string, error) {
-	reqBodyBz, _ := json.Marshal(map[string]interface{}{
-		"flow_token": flowToken,
-		"subtask_inputs": []map[string]interface{}{
-			{
-				"subtask_id": "LoginEnterUserIdentifierSSO",
-				"settings_list": map[string]interface{}{
-					"setting_responses": []map[string]interface{}{
-						{
-							"key": "user_identifier",
-							"response_data": map[string]interface{}{
-								"text_data": map[string]interface{}{
-									"result": username,
-								},
-							},
-						},
-					},
-					"link": "next_link",
-				},
-			},
-		},
-	})
-	req, err := http.NewRequest(http.MethodPost, "https://api.twitter.com/1.1/onboarding/task.json", bytes.NewReader(reqBodyBz))
-	if err != nil {
-		return "", err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	cookies := baseClient.httpClient.Jar.Cookies(req.URL)
-	for _, c := range cookies {
-		if c.Name == "gt" {
-			req.Header.Set("X-Guest-Token", c.Value)
-			break
-		}
-	}
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", defaultBearerToken))
-	req = req.WithContext(ctx)
-
-	resp, err := baseClient.httpClient.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected response code %d", resp.StatusCode)
-	}
-
-	bodyBz, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
-
-	var respBody struct {
-		FlowToken string `json:"flow_token"`
-	}
-	err = json.Unmarshal(bodyBz, &respBody)
-	if err != nil {
-		return "", err
-	}
-
-	return respBody.FlowToken, nil
-}
-
-func (baseClient *BaseClient) loginEnterPassword(ctx context.Context, flowToken string, password string) (string, error) {
-	reqBodyBz, _ := json.Marshal(map[string]interface{}{
-		"flow_token": flowToken,
-		"subtask_inputs": []map[string]interface{}{
-			{
-				"subtask_id": "LoginEnterPassword",
-				"enter_password": map[string]interface{}{
-					"password": password,
-					"link":     "next_link",
-				},
-			},
-		},
-	})
-	req, err := http.NewRequest(http.MethodPost, "https://api.twitter.com/1.1/onboarding/task.json", bytes.NewReader(reqBodyBz))
-	if err != nil {
-		return "", err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	cookies := baseClient.httpClient.Jar.Cookies(req.URL)
-	for _, c := range cookies {
-		if c.Name == "gt" {
-			req.Header.Set("X-Guest-Token", c.Value)
-			break
-		}
-	}
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", defaultBearerToken))
-	req = req.WithContext(ctx)
-
-	resp, err := baseClient.httpClient.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected response code %d", resp.StatusCode)
-	}
-
-	bodyBz, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
-
-	var respBody struct {
-		FlowToken string `json:"flow_token"`
-	}
-	err = json.Unmarshal(bodyBz, &respBody)
-	if err != nil {
-		return "", err
-	}
-
-	return respBody.FlowToken, nil
-}
-
-func (baseClient *BaseClient) accountDuplicationCheck(ctx context.Context, flowToken string) (string, error) {
-	reqBodyBz, _ := json.Marshal(map[string]interface{}{
-		"flow_token": flowToken,
-		"subtask_inputs": []map[string]interface{}{
-			{
-				"subtask_id": "AccountDuplicationCheck",
-				"check_logged_in_account": map[string]interface{}{
-					"link": "AccountDuplicationCheck_false",
-				},
-			},
-		},
-	})
-	req, err := http.NewRequest(http.MethodPost, "https://api.twitter.com/1.1/onboarding/task.json", bytes.NewReader(reqBodyBz))
-	if err != nil {
-		return "", err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	cookies := baseClient.httpClient.Jar.Cookies(req.URL)
-	for _, c := range cookies {
-		if c.Name == "gt" {
-			req.Header.Set("X-Guest-Token", c.Value)
-			break
-		}
-	}
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", defaultBearerToken))
-	req = req.WithContext(ctx)
-
-	resp, err := baseClient.httpClient.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected response code %d", resp.StatusCode)
-	}
-
-	bodyBz, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
-
-	var respBody struct {
-		FlowToken string `json:"flow_token"`
-	}
-	err = json.Unmarshal(bodyBz, &respBody)
-	if err != nil {
-		return "", err
-	}
-
-	return respBody.FlowToken, nil
-}
-
 func (baseClient *BaseClient) ensureSearchSafety(ctx context.Context) error {
 	var twid string
-	cookies := baseClient.httpClient.Jar.Cookies(twitterURL)
+	cookies := baseClient.httpClient.Jar.Cookies(baseClient.cookieURL())
 	for _, c := range cookies {
 		if c.Name == "twid" {
 			matches := regexp.MustCompile(`u=(\d+)`).FindStringSubmatch(c.Value)
@@ -582,7 +343,7 @@ func (baseClient *BaseClient) ensureSearchSafety(ctx context.Context) error {
 	})
 	req, err := http.NewRequest(
 		http.MethodPost,
-		fmt.Sprintf("https://twitter.com/i/api/1.1/strato/column/User/%s/search/searchSafety", twid),
+		fmt.Sprintf("%s/i/api/1.1/strato/column/User/%s/search/searchSafety", baseClient.hosts.Web, twid),
 		bytes.NewReader(reqBodyBz),
 	)
 	if err != nil {
@@ -606,8 +367,42 @@ func (baseClient *BaseClient) ensureSearchSafety(ctx context.Context) error {
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected response code %d", resp.StatusCode)
+		return &StatusError{StatusCode: resp.StatusCode}
 	}
 
 	return nil
 }
+
+// cookieURL is the URL the cookies of the session are scoped to.
+func (baseClient *BaseClient) cookieURL() *url.URL {
+	u, err := url.Parse(baseClient.hosts.Web + "/")
+	if err != nil {
+		panic(fmt.Sprintf("invalid web host %q: %s", baseClient.hosts.Web, err))
+	}
+
+	return u
+}
+
+// cookieDomain is the domain of the cookies of the session, shared by the web and the API hosts.
+// It is empty, i.e. host-only, if the web host is an IP, as fake servers in tests are.
+func (baseClient *BaseClient) cookieDomain() string {
+	host := baseClient.cookieURL().Hostname()
+	if net.ParseIP(host) != nil {
+		return ""
+	}
+
+	return "." + host
+}
+
+// setCookies adds cookies to the session, scoped to the cookie domain whatever domain they were exported from.
+func (baseClient *BaseClient) setCookies(cookies []*http.Cookie) {
+	domain := baseClient.cookieDomain()
+	scoped := make([]*http.Cookie, 0, len(cookies))
+	for _, c := range cookies {
+		c := *c
+		c.Domain = domain
+		scoped = append(scoped, &c)
+	}
+
+	baseClient.httpClient.Jar.SetCookies(baseClient.cookieURL(), scoped)
+}
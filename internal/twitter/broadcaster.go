@@ -0,0 +1,33 @@
+package twitter
+
+import "sync"
+
+// broadcaster wakes up every waiter at once, e.g. when a client of a pool has capacity again.
+type broadcaster struct {
+	mtx *sync.Mutex
+	ch  chan struct{}
+}
+
+func newBroadcaster() *broadcaster {
+	return &broadcaster{
+		mtx: &sync.Mutex{},
+		ch:  make(chan struct{}),
+	}
+}
+
+// wait returns a channel that is closed on the next broadcast.
+// Call it before checking the condition to not miss a broadcast in between.
+func (b *broadcaster) wait() <-chan struct{} {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	return b.ch
+}
+
+func (b *broadcaster) broadcast() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	close(b.ch)
+	b.ch = make(chan struct{})
+}
@@ -0,0 +1,404 @@
+package twitter
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CassetteMode is whether a Cassette records or replays the requests sent to Twitter.
+type CassetteMode int
+
+const (
+	// CassetteRecord sends requests through the underlying transport and writes them to the cassette.
+	CassetteRecord CassetteMode = iota
+	// CassetteReplay answers requests from the cassette without sending them.
+	CassetteReplay
+)
+
+const scrubbedValue = "REDACTED"
+
+var (
+	regexGraphQLOperation = regexp.MustCompile(`/i/api/graphql/[^/]+/(\w+)$`)
+	regexCassetteName     = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+	// scrubbedCookies are the session cookies which are never written to a cassette, twid identifies the account
+	scrubbedCookies = map[string]bool{"auth_token": true, "ct0": true, "twid": true}
+	scrubbedHeaders = []string{"X-Csrf-Token"}
+	// scrubbedFields are the JSON fields of request bodies which are never written to a cassette, either by name
+	// or by parent and name, e.g. the email, phone or 2FA code entered during the login flow
+	scrubbedFields = map[string]bool{"password": true, "enter_text.text": true}
+	// regexScrubbedPath matches the account ID in paths, e.g. of searchSafety
+	regexScrubbedPath = regexp.MustCompile(`/User/\d+/`)
+)
+
+// Cassette is an http.RoundTripper recording the requests sent to Twitter, or replaying them offline,
+// e.g. to write tests against real responses. Plug it in with WithTransport.
+//
+// A cassette is a directory with a JSON file per request. GraphQL requests are matched by operation and variables,
+// others by method and path. Requests matching several recorded ones are answered in the order they were recorded,
+// the last answer being repeated. Files are named after what requests are matched by, and the host, the Date
+// and the rate limit reset are left out, so recording again only rewrites the interactions which changed.
+// Session cookies, CSRF tokens, passwords and other login inputs are scrubbed when recording.
+type Cassette struct {
+	dir       string
+	mode      CassetteMode
+	transport http.RoundTripper
+
+	mtx          *sync.Mutex
+	counts       map[string]int // interactions recorded per file name prefix
+	interactions map[string][]*cassetteInteraction
+}
+
+type cassetteInteraction struct {
+	Request  cassetteRequest  `json:"request"`
+	Response cassetteResponse `json:"response"`
+}
+
+type cassetteRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Body   string      `json:"body,omitempty"`
+}
+
+type cassetteResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body"`
+}
+
+// NewCassette opens the cassette in dir. When recording, dir is created if needed
+// and requests are sent through transport, http.DefaultTransport if nil.
+func NewCassette(dir string, mode CassetteMode, transport http.RoundTripper) (*Cassette, error) {
+	cassette := &Cassette{
+		dir:          dir,
+		mode:         mode,
+		transport:    transport,
+		mtx:          &sync.Mutex{},
+		counts:       make(map[string]int),
+		interactions: make(map[string][]*cassetteInteraction),
+	}
+	if cassette.transport == nil {
+		cassette.transport = http.DefaultTransport
+	}
+
+	switch mode {
+	case CassetteRecord:
+		err := os.MkdirAll(dir, 0o755)
+		if err != nil {
+			return nil, err
+		}
+	case CassetteReplay:
+		err := cassette.load()
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown cassette mode %d", mode)
+	}
+
+	return cassette, nil
+}
+
+func (cassette *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	if cassette.mode == CassetteReplay {
+		return cassette.replay(req)
+	}
+
+	return cassette.record(req)
+}
+
+func (cassette *Cassette) record(req *http.Request) (*http.Response, error) {
+	var reqBodyBz []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		reqBodyBz, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(reqBodyBz))
+	}
+
+	resp, err := cassette.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBodyBz, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBodyBz))
+
+	// the host is left out, it is the fake one in tests
+	recordedURL, _ := url.Parse(scrubURL(req.URL.RequestURI()))
+	interaction := &cassetteInteraction{
+		Request: cassetteRequest{
+			Method: req.Method,
+			URL:    recordedURL.String(),
+			Header: scrubHeader(req.Header),
+			Body:   string(scrubBody(reqBodyBz)),
+		},
+		Response: cassetteResponse{
+			StatusCode: resp.StatusCode,
+			Header:     normalizeHeader(scrubHeader(resp.Header)),
+			Body:       string(respBodyBz),
+		},
+	}
+
+	interactionBz, err := json.MarshalIndent(interaction, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	key := cassetteKey(req.Method, recordedURL.Path, recordedURL.Query().Get("variables"))
+	keyHash := sha256.Sum256([]byte(key))
+	prefix := fmt.Sprintf("%s-%s", cassetteName(req), hex.EncodeToString(keyHash[:4]))
+
+	cassette.mtx.Lock()
+	defer cassette.mtx.Unlock()
+
+	// appends to the cassette, if any
+	count, ok := cassette.counts[prefix]
+	if !ok {
+		files, err := filepath.Glob(filepath.Join(cassette.dir, prefix+"-*.json"))
+		if err != nil {
+			return nil, err
+		}
+		count = len(files)
+	}
+	count++
+	cassette.counts[prefix] = count
+
+	name := fmt.Sprintf("%s-%03d.json", prefix, count)
+	err = os.WriteFile(filepath.Join(cassette.dir, name), interactionBz, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (cassette *Cassette) replay(req *http.Request) (*http.Response, error) {
+	key := cassetteKey(req.Method, req.URL.Path, req.URL.Query().Get("variables"))
+
+	cassette.mtx.Lock()
+	interactions := cassette.interactions[key]
+	if len(interactions) == 0 {
+		cassette.mtx.Unlock()
+		return nil, fmt.Errorf("no request recorded in %s for %s", cassette.dir, key)
+	}
+	interaction := interactions[0]
+	if len(interactions) > 1 {
+		cassette.interactions[key] = interactions[1:]
+	}
+	cassette.mtx.Unlock()
+
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+
+	header := interaction.Response.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	// the crawler ignores responses older than its last login, and rate limits of a past window
+	now := time.Now()
+	if reset, err := strconv.ParseInt(header.Get("X-Rate-Limit-Reset"), 10, 64); err == nil {
+		// relative to the recording, see normalizeHeader, unless recorded by an older version along with Date
+		recordedAt := time.Unix(0, 0)
+		if date, err := http.ParseTime(header.Get("Date")); err == nil {
+			recordedAt = date
+		}
+		header.Set("X-Rate-Limit-Reset", strconv.FormatInt(now.Unix()+reset-recordedAt.Unix(), 10))
+	}
+	header.Set("Date", now.UTC().Format(http.TimeFormat))
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+		StatusCode:    interaction.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(interaction.Response.Body)),
+		ContentLength: int64(len(interaction.Response.Body)),
+		Request:       req,
+	}, nil
+}
+
+func (cassette *Cassette) load() error {
+	files, err := filepath.Glob(filepath.Join(cassette.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no request recorded in %s", cassette.dir)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		bz, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		var interaction cassetteInteraction
+		err = json.Unmarshal(bz, &interaction)
+		if err != nil {
+			return fmt.Errorf("invalid cassette file %s: %w", file, err)
+		}
+
+		req, err := http.NewRequest(interaction.Request.Method, interaction.Request.URL, nil)
+		if err != nil {
+			return fmt.Errorf("invalid cassette file %s: %w", file, err)
+		}
+
+		key := cassetteKey(req.Method, req.URL.Path, req.URL.Query().Get("variables"))
+		cassette.interactions[key] = append(cassette.interactions[key], &interaction)
+	}
+
+	return nil
+}
+
+// cassetteKey identifies a request: its GraphQL operation and variables, or its method and path.
+func cassetteKey(method string, path string, variables string) string {
+	matches := regexGraphQLOperation.FindStringSubmatch(path)
+	if len(matches) != 2 {
+		return method + " " + path
+	}
+
+	// variables are compared regardless of the order of their keys
+	var v map[string]interface{}
+	if json.Unmarshal([]byte(variables), &v) == nil {
+		variablesBz, _ := json.Marshal(v)
+		variables = string(variablesBz)
+	}
+
+	return matches[1] + " " + variables
+}
+
+func cassetteName(req *http.Request) string {
+	if matches := regexGraphQLOperation.FindStringSubmatch(req.URL.Path); len(matches) == 2 {
+		return matches[1]
+	}
+
+	name := strings.TrimSuffix(path.Base(req.URL.Path), path.Ext(req.URL.Path))
+	if name == "/" || name == "." {
+		return "home"
+	}
+	return regexCassetteName.ReplaceAllString(name, "-")
+}
+
+func scrubHeader(header http.Header) http.Header {
+	scrubbed := header.Clone()
+	for _, name := range scrubbedHeaders {
+		if scrubbed.Get(name) != "" {
+			scrubbed.Set(name, scrubbedValue)
+		}
+	}
+
+	if cookieHeader := scrubbed.Get("Cookie"); cookieHeader != "" {
+		cookies := make([]string, 0)
+		for _, c := range (&http.Request{Header: http.Header{"Cookie": {cookieHeader}}}).Cookies() {
+			if scrubbedCookies[c.Name] {
+				c.Value = scrubbedValue
+			}
+			cookies = append(cookies, c.String())
+		}
+		scrubbed.Set("Cookie", strings.Join(cookies, "; "))
+	}
+
+	if setCookies := scrubbed.Values("Set-Cookie"); len(setCookies) > 0 {
+		scrubbed.Del("Set-Cookie")
+		for _, setCookie := range setCookies {
+			name, _, _ := strings.Cut(setCookie, "=")
+			if scrubbedCookies[strings.TrimSpace(name)] {
+				_, attributes, _ := strings.Cut(setCookie, ";")
+				setCookie = name + "=" + scrubbedValue
+				if attributes != "" {
+					setCookie += ";" + attributes
+				}
+			}
+			scrubbed.Add("Set-Cookie", setCookie)
+		}
+	}
+
+	return scrubbed
+}
+
+// normalizeHeader leaves out what changes every time a response is recorded: Date is dropped
+// and X-Rate-Limit-Reset made relative to it, rounded up to the minute.
+func normalizeHeader(header http.Header) http.Header {
+	recordedAt, err := http.ParseTime(header.Get("Date"))
+	if err != nil {
+		recordedAt = time.Now()
+	}
+	header.Del("Date")
+
+	if reset, err := strconv.ParseInt(header.Get("X-Rate-Limit-Reset"), 10, 64); err == nil {
+		remaining := time.Unix(reset, 0).Sub(recordedAt)
+		if remaining < 0 {
+			remaining = 0
+		}
+		remaining = (remaining + time.Minute - 1).Truncate(time.Minute)
+		header.Set("X-Rate-Limit-Reset", strconv.FormatInt(int64(remaining.Seconds()), 10))
+	}
+
+	return header
+}
+
+// scrubBody scrubs the passwords from a JSON body, other bodies are kept as is.
+func scrubBody(bz []byte) []byte {
+	var v interface{}
+	if json.Unmarshal(bz, &v) != nil {
+		return bz
+	}
+
+	scrubbedBz, err := json.Marshal(scrubFields("", v))
+	if err != nil {
+		return bz
+	}
+	return scrubbedBz
+}
+
+// scrubFields scrubs the fields of v, parent being the name of the field holding v.
+func scrubFields(parent string, v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if scrubbedFields[key] || scrubbedFields[parent+"."+key] {
+				v[key] = scrubbedValue
+				continue
+			}
+			v[key] = scrubFields(key, value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = scrubFields(parent, value)
+		}
+	}
+
+	return v
+}
+
+func scrubURL(u string) string {
+	return regexScrubbedPath.ReplaceAllString(u, "/User/"+scrubbedValue+"/")
+}
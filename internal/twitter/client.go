@@ -1,11 +1,8 @@
 package twitter
 
 import (
-	"context"
 	"encoding/json"
-	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"strconv"
 	"sync"
@@ -18,26 +15,41 @@ type Client struct {
 	callLimit  int64
 
 	forbidden bool
+	disabled  bool // taken out of rotation by an admin
 	pending   int64
 	remaining int64
 	reset     int64
 
-	mtx *sync.Mutex
+	lastError   string
+	lastErrorAt time.Time
+
+	// re-probing of a forbidden client, see probeForbidden
+	probeAttempts int
+	nextProbeAt   time.Time
+
+	capacity    *broadcaster // notified whenever a pending request is done
+	reconnector *reconnector // shared by the clients of the same account
+	mtx         *sync.Mutex
 }
 
-func (client *Client) isAvailable() (bool, int64) {
-	if !client.baseClient.Connected() || client.forbidden {
+// isAvailable reserves a request slot unless fewer than reserve slots would be left afterwards.
+func (client *Client) isAvailable(reserve int64) (bool, int64) {
+	if !client.baseClient.Connected() {
 		return false, -1
 	}
 
 	client.mtx.Lock()
 	defer client.mtx.Unlock()
 
+	if client.forbidden || client.disabled {
+		return false, -1
+	}
+
 	if client.reset < time.Now().Unix() {
-		if client.pending >= client.callLimit {
+		if client.pending+reserve >= client.callLimit {
 			return false, time.Now().Add(15 * time.Minute).Unix()
 		}
-	} else if client.pending >= client.remaining {
+	} else if client.pending+reserve >= client.remaining {
 		return false, client.reset
 	}
 
@@ -53,22 +65,24 @@ func (client *Client) handleResponse(statusCode int, header http.Header, body []
 		return
 	}
 
-	// ignore if SetAuthData has been called recently
+	// ignore if SetAuthData has been called recently, Date only has a precision of a second
 	responseTime, _ := time.Parse(time.RFC1123, header.Get("Date"))
-	if responseTime.Before(client.baseClient.LastSyncedAt()) {
+	if responseTime.Before(client.baseClient.LastSyncedAt().Truncate(time.Second)) {
 		return
 	}
 
 	client.pending--
+	defer client.capacity.broadcast()
 
 	if statusCode == http.StatusUnauthorized {
+		client.recordError(&StatusError{StatusCode: statusCode})
 		client.baseClient.Disconnect()
-		go client.reconnect()
+		client.reconnect()
 		return
 	}
 
 	if statusCode == http.StatusForbidden {
-		client.forbidden = true
+		client.setForbidden(&StatusError{StatusCode: statusCode})
 		return
 	}
 
@@ -80,8 +94,9 @@ func (client *Client) handleResponse(statusCode int, header http.Header, body []
 	if err == nil && len(respBody.Errors) > 0 {
 		for _, e := range respBody.Errors {
 			if e.Name == "AuthorizationError" {
+				client.recordError(e)
 				client.baseClient.Disconnect()
-				go client.reconnect()
+				client.reconnect()
 				return
 			}
 		}
@@ -102,53 +117,68 @@ func (client *Client) handleResponse(statusCode int, header http.Header, body []
 	}
 }
 
+// release gives back the slot acquired by isAvailable when the request could not be sent.
+func (client *Client) release() {
+	client.mtx.Lock()
+	client.pending--
+	client.mtx.Unlock()
+
+	client.capacity.broadcast()
+}
+
+// reconnect logs the account in again in the background, see reconnector.
 func (client *Client) reconnect() {
-	if !client.baseClient.Connected() {
-		// already in connecting progress
+	if client.reconnector == nil {
+		client.baseClient.logger.Warn("session is rejected but the client cannot reconnect")
 		return
 	}
 
-	if !client.baseClient.CanReconnect() {
-		log.Printf("[WARN] client (%s) encountered an error but cannot reconnect", client.baseClient.Username)
-	}
-
-	ctx := context.Background()
-	attempt := 0
-	for {
-		log.Printf("[INFO] client (%s) trying to reconnect...", client.baseClient.Username)
-		err := func() error {
-			err := client.baseClient.Login(ctx)
-			if err != nil {
-				return err
-			}
-			err = client.fetchLimit()
-			if err != nil {
-				return err
-			}
+	client.reconnector.trigger()
+}
 
-			return nil
-		}()
-		if err != nil {
-			sleep := (1 << attempt) * time.Second
-			attempt++
-			log.Printf("[INFO] client (%s) failed to reconnect: %s, sleeping %s", client.baseClient.Username, err, sleep)
-			time.Sleep(sleep)
-			continue
-		}
+// recordError remembers err as the last error of the client, mtx must be held.
+func (client *Client) recordError(err error) {
+	client.lastError = err.Error()
+	client.lastErrorAt = time.Now()
+}
 
-		log.Printf("[INFO] client (%s) reconnected", client.baseClient.Username)
+// setForbidden takes the client out of rotation until a probe finds it recovered, mtx must be held.
+func (client *Client) setForbidden(err error) {
+	client.recordError(err)
+	if client.forbidden {
 		return
 	}
+
+	client.forbidden = true
+	client.probeAttempts = 0
+	client.nextProbeAt = time.Time{}
 }
 
-func (client *Client) fetchLimit() error {
+func (client *Client) fetchLimit() (err error) {
 	req, _ := http.NewRequest(http.MethodGet, client.baseURL, nil)
 	resp, err := client.baseClient.DoRequestWithAuth(req)
 	if err != nil {
+		client.mtx.Lock()
+		client.recordError(err)
+		client.mtx.Unlock()
 		return err
 	}
 	defer func() { _ = resp.Body.Close() }()
 
+	client.mtx.Lock()
+	defer client.mtx.Unlock()
+	defer func() {
+		if err != nil {
+			client.recordError(err)
+		}
+	}()
+
+	if resp.StatusCode == http.StatusForbidden {
+		// rate limit headers are not always set on 403s
+		client.setForbidden(&StatusError{StatusCode: resp.StatusCode})
+		return nil
+	}
+
 	newRemaining, err := strconv.ParseInt(resp.Header.Get("X-Rate-Limit-Remaining"), 10, 64)
 	if err != nil {
 		return err
@@ -176,15 +206,12 @@ func (client *Client) fetchLimit() error {
 		if err == nil && len(respBody.Errors) > 0 {
 			for _, e := range respBody.Errors {
 				if e.Name == "AuthorizationError" {
-					client.forbidden = true
+					client.setForbidden(e)
 				}
 			}
 		}
-	case http.StatusForbidden:
-		client.forbidden = true
-
 	default:
-		return fmt.Errorf("unexpected response code %d", resp.StatusCode)
+		return &StatusError{StatusCode: resp.StatusCode}
 	}
 
 	return nil
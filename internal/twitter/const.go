@@ -1,9 +1,16 @@
 package twitter
 
-import "net/url"
-
 const (
 	defaultBearerToken = "AAAAAAAAAAAAAAAAAAAAANRILgAAAAAAnNwIzUejRCOuH5E6I8xnZz4puTs%3D1Zv7ttfk8LF81IUq16cHjhLTvJu4FA33AGWWjCpTnA"
 )
 
-var twitterURL, _ = url.Parse("https://twitter.com/")
+// Hosts are the base URLs of Twitter, overridable e.g. to run against a fake server in tests.
+type Hosts struct {
+	Web string // the web app and its GraphQL API
+	API string // the REST API serving guest tokens and the login flow
+}
+
+var DefaultHosts = Hosts{
+	Web: "https://twitter.com",
+	API: "https://api.twitter.com",
+}
@@ -0,0 +1,102 @@
+package twitter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Cookies are browser-exported cookies of an account. They unmarshal from either
+//   - a raw Cookie header string, e.g. "auth_token=...; ct0=...",
+//   - a Netscape cookies.txt export, as a string,
+//   - a JSON export, an array of objects with at least name and value.
+type Cookies []*http.Cookie
+
+func (c *Cookies) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	var cookies []*http.Cookie
+	if data[0] == '[' {
+		var exported []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		}
+		err := json.Unmarshal(data, &exported)
+		if err != nil {
+			return fmt.Errorf("invalid cookies export: %w", err)
+		}
+
+		for _, e := range exported {
+			cookies = append(cookies, &http.Cookie{Name: e.Name, Value: e.Value})
+		}
+	} else {
+		var s string
+		err := json.Unmarshal(data, &s)
+		if err != nil {
+			return fmt.Errorf("cookies must be a string or an array: %w", err)
+		}
+
+		if strings.Contains(s, "\t") {
+			cookies, err = parseNetscapeCookies(s)
+			if err != nil {
+				return err
+			}
+		} else {
+			cookies = parseRawCookies(s)
+		}
+	}
+
+	*c = normalizeCookies(cookies)
+	return nil
+}
+
+func parseRawCookies(rawCookies string) []*http.Cookie {
+	header := http.Header{}
+	header.Add("Cookie", rawCookies)
+	req := http.Request{Header: header}
+	return req.Cookies()
+}
+
+// parseNetscapeCookies parses the cookies.txt format:
+// domain, include subdomains, path, secure, expiry, name and value, separated by tabs.
+// The value of an empty cookie may be left out along with its tab.
+func parseNetscapeCookies(s string) ([]*http.Cookie, error) {
+	cookies := make([]*http.Cookie, 0)
+	scanner := bufio.NewScanner(strings.NewReader(s))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		// trailing tabs are kept, they separate empty values
+		line := strings.TrimLeft(strings.TrimRight(scanner.Text(), "\r\n"), " ")
+		line = strings.TrimPrefix(line, "#HttpOnly_")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) == 6 {
+			fields = append(fields, "")
+		}
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("invalid cookies.txt line %d: expected 7 fields, got %d", lineNo, len(fields))
+		}
+
+		cookies = append(cookies, &http.Cookie{Name: fields[5], Value: fields[6]})
+	}
+
+	return cookies, scanner.Err()
+}
+
+// normalizeCookies scopes cookies to twitter.com, whatever domain they were exported from.
+func normalizeCookies(cookies []*http.Cookie) []*http.Cookie {
+	for _, c := range cookies {
+		c.Path = "/"
+		c.Domain = ".twitter.com"
+	}
+
+	return cookies
+}
@@ -0,0 +1,41 @@
+package twitter
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/hiendaovinh/toolkit/pkg/arr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCredentialCookies(t *testing.T) {
+	tests := map[string]string{
+		"raw":      `"auth_token=a; ct0=c"`,
+		"netscape": `"# Netscape HTTP Cookie File\n.x.com\tTRUE\t/\tTRUE\t1735689600\tauth_token\ta\n#HttpOnly_.x.com\tTRUE\t/\tTRUE\t1735689600\tct0\tc\n"`,
+		"json":     `[{"domain":".x.com","name":"auth_token","value":"a"},{"domain":".x.com","name":"ct0","value":"c","httpOnly":true}]`,
+	}
+
+	for name, cookies := range tests {
+		t.Run(name, func(t *testing.T) {
+			var credential Credential
+			err := json.Unmarshal([]byte(`{"username":"u","cookies":`+cookies+`}`), &credential)
+			assert.NoError(t, err)
+			assert.Len(t, credential.Cookies, 2)
+			for i, expected := range [][2]string{{"auth_token", "a"}, {"ct0", "c"}} {
+				assert.Equal(t, expected[0], credential.Cookies[i].Name)
+				assert.Equal(t, expected[1], credential.Cookies[i].Value)
+				assert.Equal(t, ".twitter.com", credential.Cookies[i].Domain)
+			}
+		})
+	}
+
+	var credential Credential
+	err := json.Unmarshal([]byte(`{"username":"u","cookies":"x.com\tTRUE\t/"}`), &credential)
+	assert.Error(t, err)
+
+	// empty values, with or without their trailing tab
+	cookies, err := parseNetscapeCookies(".x.com\tTRUE\t/\tTRUE\t0\tempty\t\r\n.x.com\tTRUE\t/\tTRUE\t0\tbare\n.x.com\tTRUE\t/\tTRUE\t0\tct0\tc\n")
+	assert.NoError(t, err)
+	assert.Equal(t, [][2]string{{"empty", ""}, {"bare", ""}, {"ct0", "c"}}, arr.ArrMap(cookies, func(c *http.Cookie) [2]string { return [2]string{c.Name, c.Value} }))
+}
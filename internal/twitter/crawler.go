@@ -4,15 +4,17 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
-	"math"
-	"math/rand"
+	"log/slog"
 	"net/http"
+	"reflect"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/hiendaovinh/toolkit/pkg/arr"
 )
@@ -22,47 +24,122 @@ const (
 	apiCallRetweeters     string = "retweeters"
 	apiCallFavoriters     string = "favoriters"
 	apiCallFollowing      string = "following"
+	apiCallFollowers      string = "followers"
+
+	apiCallUserByScreenName string = "user-by-screen-name"
+	apiCallUserByRestID     string = "user-by-rest-id"
+	apiCallUsersByRestIDs   string = "users-by-rest-ids"
+	apiCallTweetDetail      string = "tweet-detail"
+
+	// maxUsersPerLookup is the number of users looked up per UsersByRestIds request
+	maxUsersPerLookup = 100
+	// maxConcurrentLookups is the number of UsersByRestIds requests sent at once by UsersByRestIDs
+	maxConcurrentLookups = 4
 )
 
+// apis are the API calls made by the crawler, Path is relative to the web host.
 var apis = map[string]struct {
-	URL       string
+	Path      string
 	Variables string
 	Features  string
 	CallLimit int64
 }{
 	apiCallSearchTimeline: {
-		URL:       "https://twitter.com/i/api/graphql/tOUz374Df84NaVVr3M1p6g/SearchTimeline?variables=%7B%22rawQuery%22%3A%22quoted_tweet_id%3A1701892872574996627%22%2C%22count%22%3A20%2C%22querySource%22%3A%22tdqt%22%2C%22product%22%3A%22Top%22%7D&features=%7B%22responsive_web_graphql_exclude_directive_enabled%22%3Atrue%2C%22verified_phone_label_enabled%22%3Afalse%2C%22responsive_web_home_pinned_timelines_enabled%22%3Atrue%2C%22creator_subscriptions_tweet_preview_api_enabled%22%3Atrue%2C%22responsive_web_graphql_timeline_navigation_enabled%22%3Atrue%2C%22responsive_web_graphql_skip_user_profile_image_extensions_enabled%22%3Afalse%2C%22c9s_tweet_anatomy_moderator_badge_enabled%22%3Atrue%2C%22tweetypie_unmention_optimization_enabled%22%3Atrue%2C%22responsive_web_edit_tweet_api_enabled%22%3Atrue%2C%22graphql_is_translatable_rweb_tweet_is_translatable_enabled%22%3Atrue%2C%22view_counts_everywhere_api_enabled%22%3Atrue%2C%22longform_notetweets_consumption_enabled%22%3Atrue%2C%22responsive_web_twitter_article_tweet_consumption_enabled%22%3Afalse%2C%22tweet_awards_web_tipping_enabled%22%3Afalse%2C%22freedom_of_speech_not_reach_fetch_enabled%22%3Atrue%2C%22standardized_nudges_misinfo%22%3Atrue%2C%22tweet_with_visibility_results_prefer_gql_limited_actions_policy_enabled%22%3Atrue%2C%22longform_notetweets_rich_text_read_enabled%22%3Atrue%2C%22longform_notetweets_inline_media_enabled%22%3Atrue%2C%22responsive_web_media_download_video_enabled%22%3Afalse%2C%22responsive_web_enhance_cards_enabled%22%3Afalse%7D",
+		Path:      "/i/api/graphql/tOUz374Df84NaVVr3M1p6g/SearchTimeline?variables=%7B%22rawQuery%22%3A%22quoted_tweet_id%3A1701892872574996627%22%2C%22count%22%3A20%2C%22querySource%22%3A%22tdqt%22%2C%22product%22%3A%22Top%22%7D&features=%7B%22responsive_web_graphql_exclude_directive_enabled%22%3Atrue%2C%22verified_phone_label_enabled%22%3Afalse%2C%22responsive_web_home_pinned_timelines_enabled%22%3Atrue%2C%22creator_subscriptions_tweet_preview_api_enabled%22%3Atrue%2C%22responsive_web_graphql_timeline_navigation_enabled%22%3Atrue%2C%22responsive_web_graphql_skip_user_profile_image_extensions_enabled%22%3Afalse%2C%22c9s_tweet_anatomy_moderator_badge_enabled%22%3Atrue%2C%22tweetypie_unmention_optimization_enabled%22%3Atrue%2C%22responsive_web_edit_tweet_api_enabled%22%3Atrue%2C%22graphql_is_translatable_rweb_tweet_is_translatable_enabled%22%3Atrue%2C%22view_counts_everywhere_api_enabled%22%3Atrue%2C%22longform_notetweets_consumption_enabled%22%3Atrue%2C%22responsive_web_twitter_article_tweet_consumption_enabled%22%3Afalse%2C%22tweet_awards_web_tipping_enabled%22%3Afalse%2C%22freedom_of_speech_not_reach_fetch_enabled%22%3Atrue%2C%22standardized_nudges_misinfo%22%3Atrue%2C%22tweet_with_visibility_results_prefer_gql_limited_actions_policy_enabled%22%3Atrue%2C%22longform_notetweets_rich_text_read_enabled%22%3Atrue%2C%22longform_notetweets_inline_media_enabled%22%3Atrue%2C%22responsive_web_media_download_video_enabled%22%3Afalse%2C%22responsive_web_enhance_cards_enabled%22%3Afalse%7D",
 		CallLimit: 50,
 	},
 	apiCallRetweeters: {
-		URL:       "https://twitter.com/i/api/graphql/FnXqVNJSKmqudpmIIEeUCQ/Retweeters?variables=%7B%22tweetId%22%3A%221701892872574996627%22%2C%22count%22%3A20%2C%22includePromotedContent%22%3Atrue%7D&features=%7B%22responsive_web_graphql_exclude_directive_enabled%22%3Atrue%2C%22verified_phone_label_enabled%22%3Afalse%2C%22responsive_web_home_pinned_timelines_enabled%22%3Atrue%2C%22creator_subscriptions_tweet_preview_api_enabled%22%3Atrue%2C%22responsive_web_graphql_timeline_navigation_enabled%22%3Atrue%2C%22responsive_web_graphql_skip_user_profile_image_extensions_enabled%22%3Afalse%2C%22c9s_tweet_anatomy_moderator_badge_enabled%22%3Atrue%2C%22tweetypie_unmention_optimization_enabled%22%3Atrue%2C%22responsive_web_edit_tweet_api_enabled%22%3Atrue%2C%22graphql_is_translatable_rweb_tweet_is_translatable_enabled%22%3Atrue%2C%22view_counts_everywhere_api_enabled%22%3Atrue%2C%22longform_notetweets_consumption_enabled%22%3Atrue%2C%22responsive_web_twitter_article_tweet_consumption_enabled%22%3Afalse%2C%22tweet_awards_web_tipping_enabled%22%3Afalse%2C%22freedom_of_speech_not_reach_fetch_enabled%22%3Atrue%2C%22standardized_nudges_misinfo%22%3Atrue%2C%22tweet_with_visibility_results_prefer_gql_limited_actions_policy_enabled%22%3Atrue%2C%22longform_notetweets_rich_text_read_enabled%22%3Atrue%2C%22longform_notetweets_inline_media_enabled%22%3Atrue%2C%22responsive_web_media_download_video_enabled%22%3Afalse%2C%22responsive_web_enhance_cards_enabled%22%3Afalse%7D",
+		Path:      "/i/api/graphql/FnXqVNJSKmqudpmIIEeUCQ/Retweeters?variables=%7B%22tweetId%22%3A%221701892872574996627%22%2C%22count%22%3A20%2C%22includePromotedContent%22%3Atrue%7D&features=%7B%22responsive_web_graphql_exclude_directive_enabled%22%3Atrue%2C%22verified_phone_label_enabled%22%3Afalse%2C%22responsive_web_home_pinned_timelines_enabled%22%3Atrue%2C%22creator_subscriptions_tweet_preview_api_enabled%22%3Atrue%2C%22responsive_web_graphql_timeline_navigation_enabled%22%3Atrue%2C%22responsive_web_graphql_skip_user_profile_image_extensions_enabled%22%3Afalse%2C%22c9s_tweet_anatomy_moderator_badge_enabled%22%3Atrue%2C%22tweetypie_unmention_optimization_enabled%22%3Atrue%2C%22responsive_web_edit_tweet_api_enabled%22%3Atrue%2C%22graphql_is_translatable_rweb_tweet_is_translatable_enabled%22%3Atrue%2C%22view_counts_everywhere_api_enabled%22%3Atrue%2C%22longform_notetweets_consumption_enabled%22%3Atrue%2C%22responsive_web_twitter_article_tweet_consumption_enabled%22%3Afalse%2C%22tweet_awards_web_tipping_enabled%22%3Afalse%2C%22freedom_of_speech_not_reach_fetch_enabled%22%3Atrue%2C%22standardized_nudges_misinfo%22%3Atrue%2C%22tweet_with_visibility_results_prefer_gql_limited_actions_policy_enabled%22%3Atrue%2C%22longform_notetweets_rich_text_read_enabled%22%3Atrue%2C%22longform_notetweets_inline_media_enabled%22%3Atrue%2C%22responsive_web_media_download_video_enabled%22%3Afalse%2C%22responsive_web_enhance_cards_enabled%22%3Afalse%7D",
 		CallLimit: 500,
 	},
 	apiCallFavoriters: {
-		URL:       "https://twitter.com/i/api/graphql/zXD9lMy1-V_N1OcON9JtEQ/Favoriters?variables=%7B%22tweetId%22%3A%221701892872574996627%22%2C%22count%22%3A20%2C%22includePromotedContent%22%3Atrue%7D&features=%7B%22responsive_web_graphql_exclude_directive_enabled%22%3Atrue%2C%22verified_phone_label_enabled%22%3Afalse%2C%22responsive_web_home_pinned_timelines_enabled%22%3Atrue%2C%22creator_subscriptions_tweet_preview_api_enabled%22%3Atrue%2C%22responsive_web_graphql_timeline_navigation_enabled%22%3Atrue%2C%22responsive_web_graphql_skip_user_profile_image_extensions_enabled%22%3Afalse%2C%22c9s_tweet_anatomy_moderator_badge_enabled%22%3Atrue%2C%22tweetypie_unmention_optimization_enabled%22%3Atrue%2C%22responsive_web_edit_tweet_api_enabled%22%3Atrue%2C%22graphql_is_translatable_rweb_tweet_is_translatable_enabled%22%3Atrue%2C%22view_counts_everywhere_api_enabled%22%3Atrue%2C%22longform_notetweets_consumption_enabled%22%3Atrue%2C%22responsive_web_twitter_article_tweet_consumption_enabled%22%3Afalse%2C%22tweet_awards_web_tipping_enabled%22%3Afalse%2C%22freedom_of_speech_not_reach_fetch_enabled%22%3Atrue%2C%22standardized_nudges_misinfo%22%3Atrue%2C%22tweet_with_visibility_results_prefer_gql_limited_actions_policy_enabled%22%3Atrue%2C%22longform_notetweets_rich_text_read_enabled%22%3Atrue%2C%22longform_notetweets_inline_media_enabled%22%3Atrue%2C%22responsive_web_media_download_video_enabled%22%3Afalse%2C%22responsive_web_enhance_cards_enabled%22%3Afalse%7D",
+		Path:      "/i/api/graphql/zXD9lMy1-V_N1OcON9JtEQ/Favoriters?variables=%7B%22tweetId%22%3A%221701892872574996627%22%2C%22count%22%3A20%2C%22includePromotedContent%22%3Atrue%7D&features=%7B%22responsive_web_graphql_exclude_directive_enabled%22%3Atrue%2C%22verified_phone_label_enabled%22%3Afalse%2C%22responsive_web_home_pinned_timelines_enabled%22%3Atrue%2C%22creator_subscriptions_tweet_preview_api_enabled%22%3Atrue%2C%22responsive_web_graphql_timeline_navigation_enabled%22%3Atrue%2C%22responsive_web_graphql_skip_user_profile_image_extensions_enabled%22%3Afalse%2C%22c9s_tweet_anatomy_moderator_badge_enabled%22%3Atrue%2C%22tweetypie_unmention_optimization_enabled%22%3Atrue%2C%22responsive_web_edit_tweet_api_enabled%22%3Atrue%2C%22graphql_is_translatable_rweb_tweet_is_translatable_enabled%22%3Atrue%2C%22view_counts_everywhere_api_enabled%22%3Atrue%2C%22longform_notetweets_consumption_enabled%22%3Atrue%2C%22responsive_web_twitter_article_tweet_consumption_enabled%22%3Afalse%2C%22tweet_awards_web_tipping_enabled%22%3Afalse%2C%22freedom_of_speech_not_reach_fetch_enabled%22%3Atrue%2C%22standardized_nudges_misinfo%22%3Atrue%2C%22tweet_with_visibility_results_prefer_gql_limited_actions_policy_enabled%22%3Atrue%2C%22longform_notetweets_rich_text_read_enabled%22%3Atrue%2C%22longform_notetweets_inline_media_enabled%22%3Atrue%2C%22responsive_web_media_download_video_enabled%22%3Afalse%2C%22responsive_web_enhance_cards_enabled%22%3Afalse%7D",
 		CallLimit: 500,
 	},
 	apiCallFollowing: {
-		URL:       "https://twitter.com/i/api/graphql/OueaMJOJ0r0lmGTxl2V4Mw/Following?variables=%7B%22userId%22%3A%221415522287126671363%22%2C%22count%22%3A20%2C%22includePromotedContent%22%3Afalse%7D&features=%7B%22responsive_web_graphql_exclude_directive_enabled%22%3Atrue%2C%22verified_phone_label_enabled%22%3Afalse%2C%22responsive_web_home_pinned_timelines_enabled%22%3Atrue%2C%22creator_subscriptions_tweet_preview_api_enabled%22%3Atrue%2C%22responsive_web_graphql_timeline_navigation_enabled%22%3Atrue%2C%22responsive_web_graphql_skip_user_profile_image_extensions_enabled%22%3Afalse%2C%22c9s_tweet_anatomy_moderator_badge_enabled%22%3Atrue%2C%22tweetypie_unmention_optimization_enabled%22%3Atrue%2C%22responsive_web_edit_tweet_api_enabled%22%3Atrue%2C%22graphql_is_translatable_rweb_tweet_is_translatable_enabled%22%3Atrue%2C%22view_counts_everywhere_api_enabled%22%3Atrue%2C%22longform_notetweets_consumption_enabled%22%3Atrue%2C%22responsive_web_twitter_article_tweet_consumption_enabled%22%3Afalse%2C%22tweet_awards_web_tipping_enabled%22%3Afalse%2C%22freedom_of_speech_not_reach_fetch_enabled%22%3Atrue%2C%22standardized_nudges_misinfo%22%3Atrue%2C%22tweet_with_visibility_results_prefer_gql_limited_actions_policy_enabled%22%3Atrue%2C%22longform_notetweets_rich_text_read_enabled%22%3Atrue%2C%22longform_notetweets_inline_media_enabled%22%3Atrue%2C%22responsive_web_media_download_video_enabled%22%3Afalse%2C%22responsive_web_enhance_cards_enabled%22%3Afalse%7D",
+		Path:      "/i/api/graphql/OueaMJOJ0r0lmGTxl2V4Mw/Following?variables=%7B%22userId%22%3A%221415522287126671363%22%2C%22count%22%3A20%2C%22includePromotedContent%22%3Afalse%7D&features=%7B%22responsive_web_graphql_exclude_directive_enabled%22%3Atrue%2C%22verified_phone_label_enabled%22%3Afalse%2C%22responsive_web_home_pinned_timelines_enabled%22%3Atrue%2C%22creator_subscriptions_tweet_preview_api_enabled%22%3Atrue%2C%22responsive_web_graphql_timeline_navigation_enabled%22%3Atrue%2C%22responsive_web_graphql_skip_user_profile_image_extensions_enabled%22%3Afalse%2C%22c9s_tweet_anatomy_moderator_badge_enabled%22%3Atrue%2C%22tweetypie_unmention_optimization_enabled%22%3Atrue%2C%22responsive_web_edit_tweet_api_enabled%22%3Atrue%2C%22graphql_is_translatable_rweb_tweet_is_translatable_enabled%22%3Atrue%2C%22view_counts_everywhere_api_enabled%22%3Atrue%2C%22longform_notetweets_consumption_enabled%22%3Atrue%2C%22responsive_web_twitter_article_tweet_consumption_enabled%22%3Afalse%2C%22tweet_awards_web_tipping_enabled%22%3Afalse%2C%22freedom_of_speech_not_reach_fetch_enabled%22%3Atrue%2C%22standardized_nudges_misinfo%22%3Atrue%2C%22tweet_with_visibility_results_prefer_gql_limited_actions_policy_enabled%22%3Atrue%2C%22longform_notetweets_rich_text_read_enabled%22%3Atrue%2C%22longform_notetweets_inline_media_enabled%22%3Atrue%2C%22responsive_web_media_download_video_enabled%22%3Afalse%2C%22responsive_web_enhance_cards_enabled%22%3Afalse%7D",
+		CallLimit: 500,
+	},
+	apiCallFollowers: {
+		Path:      "/i/api/graphql/rRXFSG5vR6drKr5M37YOTw/Followers?variables=%7B%22userId%22%3A%221415522287126671363%22%2C%22count%22%3A20%2C%22includePromotedContent%22%3Afalse%7D&features=%7B%22responsive_web_graphql_exclude_directive_enabled%22%3Atrue%2C%22verified_phone_label_enabled%22%3Afalse%2C%22responsive_web_home_pinned_timelines_enabled%22%3Atrue%2C%22creator_subscriptions_tweet_preview_api_enabled%22%3Atrue%2C%22responsive_web_graphql_timeline_navigation_enabled%22%3Atrue%2C%22responsive_web_graphql_skip_user_profile_image_extensions_enabled%22%3Afalse%2C%22c9s_tweet_anatomy_moderator_badge_enabled%22%3Atrue%2C%22tweetypie_unmention_optimization_enabled%22%3Atrue%2C%22responsive_web_edit_tweet_api_enabled%22%3Atrue%2C%22graphql_is_translatable_rweb_tweet_is_translatable_enabled%22%3Atrue%2C%22view_counts_everywhere_api_enabled%22%3Atrue%2C%22longform_notetweets_consumption_enabled%22%3Atrue%2C%22responsive_web_twitter_article_tweet_consumption_enabled%22%3Afalse%2C%22tweet_awards_web_tipping_enabled%22%3Afalse%2C%22freedom_of_speech_not_reach_fetch_enabled%22%3Atrue%2C%22standardized_nudges_misinfo%22%3Atrue%2C%22tweet_with_visibility_results_prefer_gql_limited_actions_policy_enabled%22%3Atrue%2C%22longform_notetweets_rich_text_read_enabled%22%3Atrue%2C%22longform_notetweets_inline_media_enabled%22%3Atrue%2C%22responsive_web_media_download_video_enabled%22%3Afalse%2C%22responsive_web_enhance_cards_enabled%22%3Afalse%7D",
+		CallLimit: 500,
+	},
+	apiCallUserByScreenName: {
+		Path:      "/i/api/graphql/G3KGOASz96M-Qu0nwmGXNg/UserByScreenName?variables=%7B%22screen_name%22%3A%22elonmusk%22%2C%22withSafetyModeUserFields%22%3Atrue%7D&features=%7B%22hidden_profile_likes_enabled%22%3Afalse%2C%22hidden_profile_subscriptions_enabled%22%3Atrue%2C%22responsive_web_graphql_exclude_directive_enabled%22%3Atrue%2C%22verified_phone_label_enabled%22%3Afalse%2C%22subscriptions_verification_info_is_identity_verified_enabled%22%3Afalse%2C%22subscriptions_verification_info_verified_since_enabled%22%3Atrue%2C%22highlights_tweets_tab_ui_enabled%22%3Atrue%2C%22creator_subscriptions_tweet_preview_api_enabled%22%3Atrue%2C%22responsive_web_graphql_skip_user_profile_image_extensions_enabled%22%3Afalse%2C%22responsive_web_graphql_timeline_navigation_enabled%22%3Atrue%7D",
+		CallLimit: 95,
+	},
+	apiCallUserByRestID: {
+		Path:      "/i/api/graphql/QdS5LJDl99iL_KUzckdfNQ/UserByRestID?variables=%7B%22userId%22%3A%2244196397%22%2C%22withSafetyModeUserFields%22%3Atrue%7D&features=%7B%22hidden_profile_likes_enabled%22%3Afalse%2C%22hidden_profile_subscriptions_enabled%22%3Atrue%2C%22responsive_web_graphql_exclude_directive_enabled%22%3Atrue%2C%22verified_phone_label_enabled%22%3Afalse%2C%22subscriptions_verification_info_is_identity_verified_enabled%22%3Afalse%2C%22subscriptions_verification_info_verified_since_enabled%22%3Atrue%2C%22highlights_tweets_tab_ui_enabled%22%3Atrue%2C%22creator_subscriptions_tweet_preview_api_enabled%22%3Atrue%2C%22responsive_web_graphql_skip_user_profile_image_extensions_enabled%22%3Afalse%2C%22responsive_web_graphql_timeline_navigation_enabled%22%3Atrue%7D",
 		CallLimit: 500,
 	},
+	apiCallUsersByRestIDs: {
+		Path:      "/i/api/graphql/GD4q8bBE2i6cqWw2iT74Gg/UsersByRestIds?variables=%7B%22userIds%22%3A%5B%2244196397%22%5D%7D&features=%7B%22hidden_profile_likes_enabled%22%3Afalse%2C%22hidden_profile_subscriptions_enabled%22%3Atrue%2C%22responsive_web_graphql_exclude_directive_enabled%22%3Atrue%2C%22verified_phone_label_enabled%22%3Afalse%2C%22subscriptions_verification_info_is_identity_verified_enabled%22%3Afalse%2C%22subscriptions_verification_info_verified_since_enabled%22%3Atrue%2C%22highlights_tweets_tab_ui_enabled%22%3Atrue%2C%22creator_subscriptions_tweet_preview_api_enabled%22%3Atrue%2C%22responsive_web_graphql_skip_user_profile_image_extensions_enabled%22%3Afalse%2C%22responsive_web_graphql_timeline_navigation_enabled%22%3Atrue%7D",
+		CallLimit: 500,
+	},
+	apiCallTweetDetail: {
+		Path:      "/i/api/graphql/VWFGPVAGkZMGRKGe3GFFnA/TweetDetail?variables=%7B%22focalTweetId%22%3A%221701892872574996627%22%2C%22with_rux_injections%22%3Afalse%2C%22includePromotedContent%22%3Atrue%2C%22withCommunity%22%3Atrue%2C%22withQuickPromoteEligibilityTweetFields%22%3Atrue%2C%22withBirdwatchNotes%22%3Atrue%2C%22withVoice%22%3Atrue%2C%22withV2Timeline%22%3Atrue%7D&features=%7B%22responsive_web_graphql_exclude_directive_enabled%22%3Atrue%2C%22verified_phone_label_enabled%22%3Afalse%2C%22responsive_web_home_pinned_timelines_enabled%22%3Atrue%2C%22creator_subscriptions_tweet_preview_api_enabled%22%3Atrue%2C%22responsive_web_graphql_timeline_navigation_enabled%22%3Atrue%2C%22responsive_web_graphql_skip_user_profile_image_extensions_enabled%22%3Afalse%2C%22c9s_tweet_anatomy_moderator_badge_enabled%22%3Atrue%2C%22tweetypie_unmention_optimization_enabled%22%3Atrue%2C%22responsive_web_edit_tweet_api_enabled%22%3Atrue%2C%22graphql_is_translatable_rweb_tweet_is_translatable_enabled%22%3Atrue%2C%22view_counts_everywhere_api_enabled%22%3Atrue%2C%22longform_notetweets_consumption_enabled%22%3Atrue%2C%22responsive_web_twitter_article_tweet_consumption_enabled%22%3Afalse%2C%22tweet_awards_web_tipping_enabled%22%3Afalse%2C%22freedom_of_speech_not_reach_fetch_enabled%22%3Atrue%2C%22standardized_nudges_misinfo%22%3Atrue%2C%22tweet_with_visibility_results_prefer_gql_limited_actions_policy_enabled%22%3Atrue%2C%22longform_notetweets_rich_text_read_enabled%22%3Atrue%2C%22longform_notetweets_inline_media_enabled%22%3Atrue%2C%22responsive_web_media_download_video_enabled%22%3Afalse%2C%22responsive_web_enhance_cards_enabled%22%3Afalse%7D",
+		CallLimit: 150,
+	},
 }
 
 type Crawler struct {
-	clients map[string]map[string]*Client
+	clients    map[string]map[string]*Client // api name -> username -> client
+	schedulers map[string]*scheduler
+	synced     map[string]bool // usernames of the accounts managed by SyncCredentials
+	mtx        *sync.RWMutex   // guards clients and synced
+
+	logger          *slog.Logger
+	waitForCapacity bool
+	sessionStore    SessionStore
+	hosts           Hosts
+	transport       http.RoundTripper // of the HTTP clients of the accounts, http.DefaultTransport if nil
+	probePolicy     BackoffPolicy
+	reconnectPolicy BackoffPolicy
+	metrics         *metrics
+
+	ctx    context.Context // done when the crawler is closed
+	cancel context.CancelFunc
+	wg     *sync.WaitGroup // background goroutines
 }
 
 var _ ICrawlAPI = (*Crawler)(nil)
 
-func NewCrawler(credentials []Credential) (*Crawler, error) {
+func NewCrawler(credentials []Credential, opts ...Option) (*Crawler, error) {
 	clients := make(map[string]map[string]*Client)
-	crawler := &Crawler{clients: clients}
+	crawler := &Crawler{
+		clients:         clients,
+		schedulers:      make(map[string]*scheduler),
+		synced:          make(map[string]bool),
+		mtx:             &sync.RWMutex{},
+		probePolicy:     defaultProbePolicy,
+		reconnectPolicy: defaultReconnectPolicy,
+		wg:              &sync.WaitGroup{},
+		logger:          slog.Default(),
+		hosts:           DefaultHosts,
+	}
+	crawler.ctx, crawler.cancel = context.WithCancel(context.Background())
+	for apiName := range apis {
+		apiName := apiName
+		crawler.schedulers[apiName] = newScheduler(func() []*Client {
+			return crawler.apiClients(apiName)
+		})
+	}
+	for _, opt := range opts {
+		opt(crawler)
+	}
 
 	crawler.init(credentials)
+
+	crawler.wg.Add(1)
+	go func() {
+		defer crawler.wg.Done()
+		crawler.checkForbidden(crawler.ctx)
+	}()
+
 	return crawler, nil
 }
 
-func NewCrawlerFromEnvs(vs map[string]string) (*Crawler, error) {
+func NewCrawlerFromEnvs(vs map[string]string, opts ...Option) (*Crawler, error) {
 	var twitterCredentials []Credential
 	credentialsStr := vs["TWITTER_CREDENTIALS"]
 
@@ -75,47 +152,293 @@ func NewCrawlerFromEnvs(vs map[string]string) (*Crawler, error) {
 		return nil, err
 	}
 
-	return NewCrawler(twitterCredentials)
+	return NewCrawler(twitterCredentials, opts...)
 }
 
 func (crawler *Crawler) init(credentials []Credential) {
 	ctx := context.Background()
 	wg := &sync.WaitGroup{}
 
-	for idx, credential := range credentials {
+	for _, credential := range credentials {
 		wg.Add(1)
-		go func(ctx context.Context, wg *sync.WaitGroup, idx int, credential Credential) {
+		go func(ctx context.Context, wg *sync.WaitGroup, credential Credential) {
 			defer wg.Done()
-			baseClient, err := NewBaseClientFromPassword(ctx, credential.Username, credential.Password)
+			err := crawler.addCredential(ctx, credential, true)
 			if err != nil {
-				log.Printf("[WARN] skipping twitter (%s) due to error: %s", credential.Username, err)
-				return
+				crawler.logger.Warn("skipping account", "account", credential.Username, "error", err)
 			}
+		}(ctx, wg, credential)
+	}
 
-			for apiName, api := range apis {
-				client := &Client{
-					baseClient: baseClient,
-					baseURL:    api.URL,
-					callLimit:  api.CallLimit,
-					pending:    0,
-					remaining:  0,
-					reset:      0,
-					mtx:        &sync.Mutex{},
-				}
-				err = client.fetchLimit()
-				if err != nil {
-					log.Printf("[WARN] skipping twitter (%s) for api %s due to error: %s", credential.Username, apiName, err)
-					continue
-				}
-				if crawler.clients[apiName] == nil {
-					crawler.clients[apiName] = make(map[string]*Client)
-				}
-				crawler.clients[apiName][credential.Username] = client
-			}
-		}(ctx, wg, idx, credential)
+	wg.Wait()
+}
+
+// Close stops the background goroutines of the crawler, i.e. health checks and reconnects,
+// and saves the sessions of connected accounts. Requests in flight are not interrupted.
+func (crawler *Crawler) Close() error {
+	crawler.cancel()
+	crawler.wg.Wait()
+
+	crawler.mtx.RLock()
+	baseClients := make(map[*BaseClient]*reconnector)
+	for _, clients := range crawler.clients {
+		for _, client := range clients {
+			baseClients[client.baseClient] = client.reconnector
+		}
+	}
+	crawler.mtx.RUnlock()
+
+	var firstErr error
+	for baseClient, reconnector := range baseClients {
+		reconnector.stop()
+		reconnector.wait()
+
+		err := baseClient.persistSession()
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("cannot save session of (%s): %w", baseClient.Username, err)
+		}
+	}
+
+	return firstErr
+}
+
+// AddCredential connects an account and adds it to the pool of every API it can call.
+// An account with the same username is replaced, requests in flight on it are not interrupted.
+// The account is kept by SyncCredentials until it is removed with RemoveAccount.
+func (crawler *Crawler) AddCredential(ctx context.Context, credential Credential) error {
+	return crawler.addCredential(ctx, credential, false)
+}
+
+// addCredential adds an account, synced tells whether it is managed by SyncCredentials.
+func (crawler *Crawler) addCredential(ctx context.Context, credential Credential, synced bool) error {
+	baseClient, err := crawler.connect(ctx, credential)
+	if err != nil {
+		return err
+	}
+
+	reconnector := newReconnector(crawler.ctx, baseClient, crawler.reconnectPolicy)
+	reconnector.metrics = crawler.metrics
+	clients := make(map[string]*Client)
+	for apiName, api := range apis {
+		client := &Client{
+			baseClient:  baseClient,
+			baseURL:     crawler.hosts.Web + api.Path,
+			callLimit:   api.CallLimit,
+			pending:     0,
+			remaining:   0,
+			reset:       0,
+			capacity:    crawler.schedulers[apiName].capacity,
+			reconnector: reconnector,
+			mtx:         &sync.Mutex{},
+		}
+		err = client.fetchLimit()
+		if err != nil {
+			baseClient.logger.Warn("skipping api", "api", apiName, "error", err)
+			continue
+		}
+		clients[apiName] = client
+	}
+
+	if len(clients) == 0 {
+		reconnector.stop()
+		return fmt.Errorf("no api is available")
+	}
+	for _, client := range clients {
+		reconnector.clients = append(reconnector.clients, client)
+	}
+
+	crawler.mtx.Lock()
+	replaced := crawler.removeAccount(credential.Username)
+	for apiName, client := range clients {
+		if crawler.clients[apiName] == nil {
+			crawler.clients[apiName] = make(map[string]*Client)
+		}
+		crawler.clients[apiName][credential.Username] = client
 	}
+	crawler.synced[credential.Username] = synced
+	crawler.mtx.Unlock()
 
+	for _, client := range replaced {
+		client.reconnector.stop()
+	}
+
+	for apiName := range clients {
+		crawler.schedulers[apiName].capacity.broadcast()
+	}
+
+	return nil
+}
+
+// RemoveAccount removes an account from every pool. Requests in flight on it are not interrupted.
+func (crawler *Crawler) RemoveAccount(username string) error {
+	crawler.mtx.Lock()
+	removed := crawler.removeAccount(username)
+	crawler.mtx.Unlock()
+
+	if len(removed) == 0 {
+		return ErrAccountNotFound
+	}
+
+	for _, client := range removed {
+		client.reconnector.stop()
+	}
+
+	return nil
+}
+
+// removeAccount removes an account from every pool and returns its clients, mtx must be held.
+func (crawler *Crawler) removeAccount(username string) []*Client {
+	delete(crawler.synced, username)
+	removed := make([]*Client, 0)
+	for _, clients := range crawler.clients {
+		if client, ok := clients[username]; ok {
+			delete(clients, username)
+			removed = append(removed, client)
+		}
+	}
+
+	return removed
+}
+
+// Credentials returns the credentials of the accounts in the pools.
+func (crawler *Crawler) Credentials() []Credential {
+	crawler.mtx.RLock()
+	defer crawler.mtx.RUnlock()
+
+	credentials := make(map[string]Credential)
+	for _, clients := range crawler.clients {
+		for username, client := range clients {
+			credentials[username] = client.baseClient.Credential
+		}
+	}
+
+	return arr.ArrMap(sortedKeys(credentials), func(username string) Credential { return credentials[username] })
+}
+
+// SyncCredentials makes the pools match credentials: new or changed accounts are (re)connected,
+// and accounts which are not in credentials anymore are removed.
+// Accounts added with AddCredential are left alone, unless credentials has them too.
+func (crawler *Crawler) SyncCredentials(ctx context.Context, credentials []Credential) {
+	current := make(map[string]Credential)
+	for _, credential := range crawler.Credentials() {
+		current[credential.Username] = credential
+	}
+
+	crawler.mtx.RLock()
+	synced := make(map[string]bool, len(crawler.synced))
+	for username, ok := range crawler.synced {
+		synced[username] = ok
+	}
+	crawler.mtx.RUnlock()
+
+	wanted := make(map[string]bool)
+	wg := &sync.WaitGroup{}
+	for _, credential := range credentials {
+		wanted[credential.Username] = true
+		if existing, ok := current[credential.Username]; ok && reflect.DeepEqual(existing, credential) {
+			crawler.mtx.Lock()
+			if _, ok := crawler.synced[credential.Username]; ok {
+				crawler.synced[credential.Username] = true
+			}
+			crawler.mtx.Unlock()
+			continue
+		}
+
+		wg.Add(1)
+		go func(credential Credential) {
+			defer wg.Done()
+			err := crawler.addCredential(ctx, credential, true)
+			if err != nil {
+				crawler.logger.Warn("cannot add account", "account", credential.Username, "error", err)
+				return
+			}
+			crawler.logger.Info("account added", "account", credential.Username)
+		}(credential)
+	}
 	wg.Wait()
+
+	for username := range current {
+		if synced[username] && !wanted[username] && crawler.RemoveAccount(username) == nil {
+			crawler.logger.Info("account removed", "account", username)
+		}
+	}
+}
+
+// newBaseClient returns a base client which is not logged in yet, configured like the crawler.
+func (crawler *Crawler) newBaseClient(credential Credential) (*BaseClient, error) {
+	baseClient, err := newBaseClient(credential)
+	if err != nil {
+		return nil, err
+	}
+	baseClient.hosts = crawler.hosts
+	baseClient.httpClient.Transport = crawler.transport
+	baseClient.sessionStore = crawler.sessionStore
+	baseClient.logger = crawler.logger.With("account", credential.Username)
+
+	return baseClient, nil
+}
+
+// connect restores the saved session of credential if there is one, and logs in otherwise
+// or if the restored session is rejected.
+func (crawler *Crawler) connect(ctx context.Context, credential Credential) (*BaseClient, error) {
+	if len(credential.Cookies) > 0 {
+		return crawler.connectWithCookies(ctx, credential)
+	}
+
+	baseClient, err := crawler.newBaseClient(credential)
+	if err != nil {
+		return nil, err
+	}
+
+	restored, err := baseClient.restoreSession()
+	if err != nil {
+		baseClient.logger.Warn("cannot restore session", "error", err)
+	}
+
+	if restored {
+		err = baseClient.verifySession()
+		if err == nil {
+			baseClient.logger.Info("session restored")
+			return baseClient, nil
+		}
+		baseClient.logger.Info("restored session is rejected, logging in", "error", err)
+	}
+
+	err = baseClient.Login(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return baseClient, nil
+}
+
+// connectWithCookies uses the cookies of credential as is.
+// The client can only reconnect if credential has a password too.
+func (crawler *Crawler) connectWithCookies(ctx context.Context, credential Credential) (*BaseClient, error) {
+	// the password and friends, if any, are kept so that the client can reconnect
+	baseClient, err := crawler.newBaseClient(credential)
+	if err != nil {
+		return nil, err
+	}
+	baseClient.setCookies(credential.Cookies)
+	baseClient.connected = true
+
+	err = baseClient.verifySession()
+	if err == nil {
+		return baseClient, nil
+	}
+
+	if !baseClient.CanReconnect() {
+		return nil, fmt.Errorf("cookies are rejected: %w", err)
+	}
+
+	baseClient.logger.Info("cookies are rejected, logging in", "error", err)
+	err = baseClient.Login(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return baseClient, nil
 }
 
 var (
@@ -164,12 +487,27 @@ var (
 		"responsive_web_home_pinned_timelines_enabled":                            true,
 	})
 
+	apiUserProfileFeaturesBz, _ = json.Marshal(map[string]interface{}{
+		"hidden_profile_likes_enabled":                                      false,
+		"hidden_profile_subscriptions_enabled":                              true,
+		"responsive_web_graphql_exclude_directive_enabled":                  true,
+		"verified_phone_label_enabled":                                      false,
+		"subscriptions_verification_info_is_identity_verified_enabled":      false,
+		"subscriptions_verification_info_verified_since_enabled":            true,
+		"highlights_tweets_tab_ui_enabled":                                  true,
+		"creator_subscriptions_tweet_preview_api_enabled":                   true,
+		"responsive_web_graphql_skip_user_profile_image_extensions_enabled": false,
+		"responsive_web_graphql_timeline_navigation_enabled":                true,
+	})
+
 	cursorTypes      = map[string]bool{"Bottom": true, "ShowMoreThreads": true, "ShowMoreThreadsPrompt": true}
 	regexUserEntryID = regexp.MustCompile(`user-(\d+)`)
 )
 
 func (crawler *Crawler) Replies(ctx context.Context, tweetID string, cursor string) ([]Reply, string, error) {
-	req, _ := http.NewRequest("GET", apis[apiCallSearchTimeline].URL, nil)
+	ctx = withLogAttrs(ctx, "cursor", cursor)
+
+	req, _ := http.NewRequest("GET", crawler.apiURL(apiCallSearchTimeline), nil)
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/104.0.0.0 Safari/537.36")
 	req.Header.Set("X-Twitter-Active-User", "yes")
@@ -199,7 +537,7 @@ func (crawler *Crawler) Replies(ctx context.Context, tweetID string, cursor stri
 	defer func() { _ = res.Body.Close() }()
 
 	if res.StatusCode != http.StatusOK {
-		return nil, "", fmt.Errorf("unexpected response code %d", res.StatusCode)
+		return nil, "", &StatusError{StatusCode: res.StatusCode}
 	}
 
 	respBz, _ := io.ReadAll(res.Body)
@@ -214,10 +552,7 @@ func (crawler *Crawler) Replies(ctx context.Context, tweetID string, cursor stri
 	}
 
 	if len(respObj.Errors) > 0 {
-		return nil, "", fmt.Errorf("server returns error: %s", strings.Join(
-			arr.ArrMap(respObj.Errors, func(err Error) string { return err.Message }),
-			";",
-		))
+		return nil, "", &APIError{Errors: respObj.Errors}
 	}
 
 	replies := make([]Reply, 0)
@@ -284,11 +619,14 @@ func (crawler *Crawler) Replies(ctx context.Context, tweetID string, cursor stri
 		nextCursor = ""
 	}
 
+	crawler.metrics.observeItems("replies", len(replies))
 	return replies, nextCursor, nil
 }
 
 func (crawler *Crawler) Quotes(ctx context.Context, tweetID string, cursor string) ([]Quote, string, error) {
-	req, _ := http.NewRequest("GET", apis[apiCallSearchTimeline].URL, nil)
+	ctx = withLogAttrs(ctx, "cursor", cursor)
+
+	req, _ := http.NewRequest("GET", crawler.apiURL(apiCallSearchTimeline), nil)
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/104.0.0.0 Safari/537.36")
 	req.Header.Set("X-Twitter-Active-User", "yes")
@@ -318,7 +656,7 @@ func (crawler *Crawler) Quotes(ctx context.Context, tweetID string, cursor strin
 	defer func() { _ = res.Body.Close() }()
 
 	if res.StatusCode != http.StatusOK {
-		return nil, "", fmt.Errorf("unexpected response code %d", res.StatusCode)
+		return nil, "", &StatusError{StatusCode: res.StatusCode}
 	}
 
 	respBz, _ := io.ReadAll(res.Body)
@@ -333,10 +671,7 @@ func (crawler *Crawler) Quotes(ctx context.Context, tweetID string, cursor strin
 	}
 
 	if len(respObj.Errors) > 0 {
-		return nil, "", fmt.Errorf("server returns error: %s", strings.Join(
-			arr.ArrMap(respObj.Errors, func(err Error) string { return err.Message }),
-			";",
-		))
+		return nil, "", &APIError{Errors: respObj.Errors}
 	}
 
 	quotes := make([]Quote, 0)
@@ -402,11 +737,14 @@ func (crawler *Crawler) Quotes(ctx context.Context, tweetID string, cursor strin
 		nextCursor = ""
 	}
 
+	crawler.metrics.observeItems("quotes", len(quotes))
 	return quotes, nextCursor, nil
 }
 
 func (crawler *Crawler) Retweets(ctx context.Context, tweetID string, cursor string) ([]Retweet, string, error) {
-	req, _ := http.NewRequest("GET", apis[apiCallRetweeters].URL, nil)
+	ctx = withLogAttrs(ctx, "cursor", cursor)
+
+	req, _ := http.NewRequest("GET", crawler.apiURL(apiCallRetweeters), nil)
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/104.0.0.0 Safari/537.36")
 	req.Header.Set("X-Twitter-Active-User", "yes")
@@ -435,7 +773,7 @@ func (crawler *Crawler) Retweets(ctx context.Context, tweetID string, cursor str
 	defer func() { _ = res.Body.Close() }()
 
 	if res.StatusCode != http.StatusOK {
-		return nil, "", fmt.Errorf("unexpected response code %d", res.StatusCode)
+		return nil, "", &StatusError{StatusCode: res.StatusCode}
 	}
 
 	respBz, _ := io.ReadAll(res.Body)
@@ -450,10 +788,7 @@ func (crawler *Crawler) Retweets(ctx context.Context, tweetID string, cursor str
 	}
 
 	if len(respObj.Errors) > 0 {
-		return nil, "", fmt.Errorf("server returns error: %s", strings.Join(
-			arr.ArrMap(respObj.Errors, func(err Error) string { return err.Message }),
-			";",
-		))
+		return nil, "", &APIError{Errors: respObj.Errors}
 	}
 
 	retweeters := make([]Retweet, 0)
@@ -494,11 +829,14 @@ func (crawler *Crawler) Retweets(ctx context.Context, tweetID string, cursor str
 		nextCursor = ""
 	}
 
+	crawler.metrics.observeItems("retweets", len(retweeters))
 	return retweeters, nextCursor, nil
 }
 
 func (crawler *Crawler) Likes(ctx context.Context, tweetID string, cursor string) ([]Like, string, error) {
-	req, _ := http.NewRequest("GET", apis[apiCallFavoriters].URL, nil)
+	ctx = withLogAttrs(ctx, "cursor", cursor)
+
+	req, _ := http.NewRequest("GET", crawler.apiURL(apiCallFavoriters), nil)
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/104.0.0.0 Safari/537.36")
 	req.Header.Set("X-Twitter-Active-User", "yes")
@@ -527,7 +865,7 @@ func (crawler *Crawler) Likes(ctx context.Context, tweetID string, cursor string
 	defer func() { _ = res.Body.Close() }()
 
 	if res.StatusCode != http.StatusOK {
-		return nil, "", fmt.Errorf("unexpected response code %d", res.StatusCode)
+		return nil, "", &StatusError{StatusCode: res.StatusCode}
 	}
 
 	respBz, _ := io.ReadAll(res.Body)
@@ -542,10 +880,7 @@ func (crawler *Crawler) Likes(ctx context.Context, tweetID string, cursor string
 	}
 
 	if len(respObj.Errors) > 0 {
-		return nil, "", fmt.Errorf("server returns error: %s", strings.Join(
-			arr.ArrMap(respObj.Errors, func(err Error) string { return err.Message }),
-			";",
-		))
+		return nil, "", &APIError{Errors: respObj.Errors}
 	}
 
 	favoriters := make([]Like, 0)
@@ -586,11 +921,210 @@ func (crawler *Crawler) Likes(ctx context.Context, tweetID string, cursor string
 		nextCursor = ""
 	}
 
+	crawler.metrics.observeItems("likes", len(favoriters))
 	return favoriters, nextCursor, nil
 }
 
+// TweetDetail crawls the conversation of a tweet: the tweet itself and the threads replying to it.
+// Unlike Replies, it is not subject to what the search index drops. A thread's Cursor expands it,
+// nextCursor is either the next page of threads or the "show more replies" one.
+func (crawler *Crawler) TweetDetail(ctx context.Context, tweetID string, cursor string) (*Conversation, string, error) {
+	ctx = withLogAttrs(ctx, "cursor", cursor)
+
+	req, _ := http.NewRequest("GET", crawler.apiURL(apiCallTweetDetail), nil)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/104.0.0.0 Safari/537.36")
+	req.Header.Set("X-Twitter-Active-User", "yes")
+	req.Header.Set("X-Twitter-Auth-Type", "OAuth2Session")
+
+	v := map[string]interface{}{
+		"focalTweetId":                           tweetID,
+		"referrer":                               "tweet",
+		"with_rux_injections":                    false,
+		"includePromotedContent":                 true,
+		"withCommunity":                          true,
+		"withQuickPromoteEligibilityTweetFields": true,
+		"withBirdwatchNotes":                     true,
+		"withVoice":                              true,
+		"withV2Timeline":                         true,
+	}
+	if cursor != "" {
+		v["cursor"] = cursor
+	}
+	variablesBz, _ := json.Marshal(v)
+
+	values := req.URL.Query()
+	values.Set("variables", string(variablesBz))
+	values.Set("features", string(apiTweetFeaturesBz))
+	req.URL.RawQuery = values.Encode()
+
+	req = req.WithContext(ctx)
+	res, err := crawler.doRequest(apiCallTweetDetail, req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, "", &StatusError{StatusCode: res.StatusCode}
+	}
+
+	respBz, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var respObj TweetDetailResponse
+	err = json.Unmarshal(respBz, &respObj)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(respObj.Errors) > 0 {
+		return nil, "", &APIError{Errors: respObj.Errors}
+	}
+
+	conversation := &Conversation{Threads: make([]Thread, 0)}
+	nextCursor := ""
+	replyCount := 0
+
+	for _, instruction := range respObj.Data.ThreadedConversationWithInjectionsV2.Instructions {
+		if instruction.Type != "TimelineAddEntries" {
+			continue
+		}
+
+		for _, entry := range instruction.Entries {
+			if entry.Content.EntryType == "TimelineTimelineCursor" && cursorTypes[entry.Content.CursorType] {
+				nextCursor = entry.Content.Value
+				continue
+			}
+
+			// a single item: the focal tweet, one of its ancestors or a cursor
+			if itemContent := entry.Content.ItemContent; itemContent != nil {
+				switch itemContent.ItemType {
+				case "TimelineTimelineCursor":
+					if cursorTypes[itemContent.CursorType] {
+						nextCursor = itemContent.Value
+					}
+				case "TimelineTweet":
+					if tweet := newTweet(itemContent.TweetResults.Result); tweet != nil && tweet.ID == tweetID {
+						conversation.Tweet = tweet
+					}
+				}
+				continue
+			}
+
+			// a conversation module
+			thread := Thread{Tweets: make([]Tweet, 0, len(entry.Content.Items))}
+			for _, item := range entry.Content.Items {
+				itemContent := item.Item.ItemContent
+				if itemContent == nil {
+					continue
+				}
+
+				switch itemContent.ItemType {
+				case "TimelineTimelineCursor":
+					thread.Cursor = itemContent.Value
+				case "TimelineTweet":
+					if tweet := newTweet(itemContent.TweetResults.Result); tweet != nil {
+						thread.Tweets = append(thread.Tweets, *tweet)
+					}
+				}
+			}
+			if len(thread.Tweets) == 0 {
+				continue
+			}
+			conversation.Threads = append(conversation.Threads, thread)
+			replyCount += len(thread.Tweets)
+		}
+	}
+
+	if cursor == "" && conversation.Tweet == nil {
+		return nil, "", ErrTweetNotFound
+	}
+
+	crawler.metrics.observeItems("conversation", replyCount)
+	return conversation, nextCursor, nil
+}
+
+// newTweet converts a tweet result, nil if the tweet is unavailable (e.g. a tombstone).
+func newTweet(result TweetResult) *Tweet {
+	if result.Typename == "TweetWithVisibilityResults" && result.Tweet != nil {
+		result = *result.Tweet
+	}
+	if result.RestID == "" {
+		return nil
+	}
+
+	parts := make([]string, 0, len(result.Legacy.Entities.URLs)*2+1)
+	lastPartIndex := 0
+	for _, u := range result.Legacy.Entities.URLs {
+		parts = append(parts, result.Legacy.FullText[lastPartIndex:u.Indices[0]])
+		parts = append(parts, u.DisplayURL)
+		lastPartIndex = u.Indices[1]
+	}
+	parts = append(parts, result.Legacy.FullText[lastPartIndex:])
+
+	tweet := &Tweet{
+		ID:                result.RestID,
+		ConversationID:    result.Legacy.ConversationIDStr,
+		InReplyToStatusID: result.Legacy.InReplyToStatusIDStr,
+		UserID:            result.Core.UserResults.Result.RestID,
+		UserScreenName:    result.Core.UserResults.Result.Legacy.ScreenName,
+		UserName:          result.Core.UserResults.Result.Legacy.Name,
+		Text:              result.Legacy.FullText,
+		NormalizedText:    strings.Join(parts, ""),
+		CreatedAt:         result.Legacy.CreatedAt,
+		Hashtags:          arr.ArrMap(result.Legacy.Entities.Hashtags, func(v Hashtag) string { return v.Text }),
+		Symbols:           arr.ArrMap(result.Legacy.Entities.Symbols, func(v Symbol) string { return v.Text }),
+		ViewCount:         result.Views.Count,
+		QuoteCount:        result.Legacy.QuoteCount,
+		ReplyCount:        result.Legacy.ReplyCount,
+		RetweetCount:      result.Legacy.RetweetCount,
+		FavoriteCount:     result.Legacy.FavoriteCount,
+	}
+	if result.QuotedStatusResult != nil {
+		tweet.QuotedStatus = newTweet(result.QuotedStatusResult.Result)
+	}
+	if result.Legacy.RetweetedStatusResult != nil {
+		tweet.RetweetedStatus = newTweet(result.Legacy.RetweetedStatusResult.Result)
+	}
+
+	return tweet
+}
+
 func (crawler *Crawler) Following(ctx context.Context, targetID string, cursor string) ([]Following, string, error) {
-	req, _ := http.NewRequest("GET", apis[apiCallFollowing].URL, nil)
+	ctx = withLogAttrs(ctx, "cursor", cursor)
+
+	followings, nextCursor, err := crawler.followGraph(ctx, apiCallFollowing, targetID, cursor, 2)
+	if err != nil {
+		return nil, "", err
+	}
+
+	crawler.metrics.observeItems("following", len(followings))
+	return followings, nextCursor, nil
+}
+
+func (crawler *Crawler) Followers(ctx context.Context, targetID string, cursor string) ([]Follower, string, error) {
+	ctx = withLogAttrs(ctx, "cursor", cursor)
+
+	followings, nextCursor, err := crawler.followGraph(ctx, apiCallFollowers, targetID, cursor, 20)
+	if err != nil {
+		return nil, "", err
+	}
+
+	followers := make([]Follower, 0, len(followings))
+	for _, following := range followings {
+		followers = append(followers, Follower(following))
+	}
+
+	crawler.metrics.observeItems("followers", len(followers))
+	return followers, nextCursor, nil
+}
+
+// followGraph fetches a page of a user's Following or Followers timeline, which share the same request and response.
+func (crawler *Crawler) followGraph(ctx context.Context, call string, targetID string, cursor string, count int) ([]Following, string, error) {
+	req, _ := http.NewRequest("GET", crawler.apiURL(call), nil)
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/104.0.0.0 Safari/537.36")
 	req.Header.Set("X-Twitter-Active-User", "yes")
@@ -598,7 +1132,7 @@ func (crawler *Crawler) Following(ctx context.Context, targetID string, cursor s
 
 	v := map[string]interface{}{
 		"userId":                 targetID,
-		"count":                  2,
+		"count":                  count,
 		"includePromotedContent": false,
 	}
 	if cursor != "" {
@@ -612,17 +1146,17 @@ func (crawler *Crawler) Following(ctx context.Context, targetID string, cursor s
 	req.URL.RawQuery = values.Encode()
 
 	req = req.WithContext(ctx)
-	res, err := crawler.doRequest(apiCallFollowing, req)
+	res, err := crawler.doRequest(call, req)
 	if err != nil {
 		return nil, "", err
 	}
 	defer func() { _ = res.Body.Close() }()
 
 	if res.StatusCode != http.StatusOK {
-		return nil, "", fmt.Errorf("unexpected response code %d", res.StatusCode)
+		return nil, "", &StatusError{StatusCode: res.StatusCode}
 	}
 
-	respBz, _ := io.ReadAll(res.Body)
+	respBz, err := io.ReadAll(res.Body)
 	if err != nil {
 		return nil, "", err
 	}
@@ -634,10 +1168,15 @@ func (crawler *Crawler) Following(ctx context.Context, targetID string, cursor s
 	}
 
 	if len(respObj.Errors) > 0 {
-		return nil, "", fmt.Errorf("server returns error: %s", strings.Join(
-			arr.ArrMap(respObj.Errors, func(err Error) string { return err.Message }),
-			";",
-		))
+		return nil, "", &APIError{Errors: respObj.Errors}
+	}
+
+	switch respObj.Data.User.Result.Typename {
+	case "User":
+	case "":
+		return nil, "", ErrUserNotFound
+	default:
+		return nil, "", userUnavailableError(respObj.Data.User.Result.Reason)
 	}
 
 	followings := make([]Following, 0)
@@ -683,7 +1222,9 @@ func (crawler *Crawler) Following(ctx context.Context, targetID string, cursor s
 }
 
 func (crawler *Crawler) StatusesByScreenName(ctx context.Context, screenName string, cursor string) ([]StatusStat, string, error) {
-	req, _ := http.NewRequest("GET", apis[apiCallSearchTimeline].URL, nil)
+	ctx = withLogAttrs(ctx, "cursor", cursor)
+
+	req, _ := http.NewRequest("GET", crawler.apiURL(apiCallSearchTimeline), nil)
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/104.0.0.0 Safari/537.36")
 	req.Header.Set("X-Twitter-Active-User", "yes")
@@ -713,7 +1254,7 @@ func (crawler *Crawler) StatusesByScreenName(ctx context.Context, screenName str
 	defer func() { _ = res.Body.Close() }()
 
 	if res.StatusCode != http.StatusOK {
-		return nil, "", fmt.Errorf("unexpected response code %d", res.StatusCode)
+		return nil, "", &StatusError{StatusCode: res.StatusCode}
 	}
 
 	respBz, _ := io.ReadAll(res.Body)
@@ -728,10 +1269,7 @@ func (crawler *Crawler) StatusesByScreenName(ctx context.Context, screenName str
 	}
 
 	if len(respObj.Errors) > 0 {
-		return nil, "", fmt.Errorf("server returns error: %s", strings.Join(
-			arr.ArrMap(respObj.Errors, func(err Error) string { return err.Message }),
-			";",
-		))
+		return nil, "", &APIError{Errors: respObj.Errors}
 	}
 
 	statuses := make([]StatusStat, 0)
@@ -787,58 +1325,344 @@ func (crawler *Crawler) StatusesByScreenName(ctx context.Context, screenName str
 		nextCursor = ""
 	}
 
+	crawler.metrics.observeItems("statuses", len(statuses))
 	return statuses, nextCursor, nil
 }
 
-func (crawler *Crawler) doRequest(call string, req *http.Request) (*http.Response, error) {
-	clients := crawler.clients[call]
-	perm := rand.Perm(len(clients))
+// UserByScreenName looks up the profile of a user. Suspended users are returned with Suspended set.
+func (crawler *Crawler) UserByScreenName(ctx context.Context, screenName string) (*User, error) {
+	ctx = withLogAttrs(ctx, "screen_name", screenName)
 
-	keys := make([]string, 0, len(clients))
-	for username := range clients {
-		keys = append(keys, username)
+	user, err := crawler.lookupUser(ctx, apiCallUserByScreenName, map[string]interface{}{
+		"screen_name":              screenName,
+		"withSafetyModeUserFields": true,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	retryAfterInt64 := int64(math.MaxInt64)
-	for _, j := range perm {
-		client := clients[keys[j]]
-		// check if limited
-		ok, retryAfter := client.isAvailable()
-		if !ok {
-			if retryAfter >= 0 && retryAfter < retryAfterInt64 {
-				retryAfterInt64 = retryAfter
-			}
-			continue
+	if user.ScreenName == "" {
+		user.ScreenName = screenName
+	}
+	return user, nil
+}
+
+// UserByRestID looks up the profile of a user. Suspended users are returned with Suspended set.
+func (crawler *Crawler) UserByRestID(ctx context.Context, userID string) (*User, error) {
+	ctx = withLogAttrs(ctx, "user_id", userID)
+
+	user, err := crawler.lookupUser(ctx, apiCallUserByRestID, map[string]interface{}{
+		"userId":                   userID,
+		"withSafetyModeUserFields": true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if user.ID == "" {
+		user.ID = userID
+	}
+	return user, nil
+}
+
+func (crawler *Crawler) lookupUser(ctx context.Context, call string, v map[string]interface{}) (*User, error) {
+	req, _ := http.NewRequest("GET", crawler.apiURL(call), nil)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/104.0.0.0 Safari/537.36")
+	req.Header.Set("X-Twitter-Active-User", "yes")
+	req.Header.Set("X-Twitter-Auth-Type", "OAuth2Session")
+
+	variablesBz, _ := json.Marshal(v)
+
+	values := req.URL.Query()
+	values.Set("variables", string(variablesBz))
+	values.Set("features", string(apiUserProfileFeaturesBz))
+	req.URL.RawQuery = values.Encode()
+
+	req = req.WithContext(ctx)
+	res, err := crawler.doRequest(call, req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, &StatusError{StatusCode: res.StatusCode}
+	}
+
+	respBz, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var respObj UserResponse
+	err = json.Unmarshal(respBz, &respObj)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(respObj.Errors) > 0 {
+		return nil, &APIError{Errors: respObj.Errors}
+	}
+
+	result := respObj.Data.User.Result
+	switch result.Typename {
+	case "User":
+	case "":
+		return nil, ErrUserNotFound
+	default:
+		if result.Reason == "Suspended" {
+			return &User{ID: result.RestID, Suspended: true}, nil
 		}
+		return nil, userUnavailableError(result.Reason)
+	}
 
-		resp, err := client.baseClient.DoRequestWithAuth(req)
-		if err != nil {
-			return nil, err
+	user := newUser(result)
+	return &user, nil
+}
+
+// UsersByRestIDs looks up the profiles of many users at once, in chunks sent concurrently through the account pool.
+// Users are returned in the order of ids, those which cannot be found, including suspended ones, are left out.
+// The ids of chunks which fail are returned as failed, the call only fails when no chunk succeeds.
+func (crawler *Crawler) UsersByRestIDs(ctx context.Context, ids []string) ([]User, []string, error) {
+	ids = arr.ArrUnique(ids)
+	chunks := make([][]string, 0, len(ids)/maxUsersPerLookup+1)
+	for start := 0; start < len(ids); start += maxUsersPerLookup {
+		end := start + maxUsersPerLookup
+		if end > len(ids) {
+			end = len(ids)
 		}
+		chunks = append(chunks, ids[start:end])
+	}
 
-		statusCode := resp.StatusCode
-		header := http.Header{}
-		for headerName, headerValues := range resp.Header {
-			for _, headerValue := range headerValues {
-				header.Add(headerName, headerValue)
+	mtx := &sync.Mutex{}
+	found := make(map[string]User, len(ids))
+	failed := make([]string, 0)
+	succeeded := 0
+	var firstErr error
+
+	wg := &sync.WaitGroup{}
+	sem := make(chan struct{}, maxConcurrentLookups)
+	for i, chunk := range chunks {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			mtx.Lock()
+			if firstErr == nil {
+				firstErr = ctx.Err()
+			}
+			for _, rest := range chunks[i:] {
+				failed = append(failed, rest...)
 			}
+			mtx.Unlock()
+			break
 		}
 
-		var save io.ReadCloser
-		if resp.Body != nil && resp.Body != http.NoBody {
-			save, resp.Body, err = drainBody(resp.Body)
+		wg.Add(1)
+		go func(chunk []string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			users, err := crawler.lookupUsers(ctx, chunk)
+
+			mtx.Lock()
+			defer mtx.Unlock()
 			if err != nil {
-				return nil, err
+				if firstErr == nil {
+					firstErr = err
+				}
+				failed = append(failed, chunk...)
+				return
 			}
+			succeeded++
+			for _, user := range users {
+				found[user.ID] = user
+			}
+		}(chunk)
+	}
+	wg.Wait()
+
+	if len(chunks) > 0 && succeeded == 0 {
+		return nil, nil, firstErr
+	}
+
+	users := make([]User, 0, len(found))
+	for _, id := range ids {
+		if user, ok := found[id]; ok {
+			users = append(users, user)
 		}
+	}
+
+	return users, failed, nil
+}
+
+// lookupUsers looks up a single chunk of UsersByRestIDs.
+func (crawler *Crawler) lookupUsers(ctx context.Context, ids []string) ([]User, error) {
+	ctx = withLogAttrs(ctx, "user_ids", len(ids))
+
+	req, _ := http.NewRequest("GET", crawler.apiURL(apiCallUsersByRestIDs), nil)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/104.0.0.0 Safari/537.36")
+	req.Header.Set("X-Twitter-Active-User", "yes")
+	req.Header.Set("X-Twitter-Auth-Type", "OAuth2Session")
+
+	variablesBz, _ := json.Marshal(map[string]interface{}{
+		"userIds": ids,
+	})
+
+	values := req.URL.Query()
+	values.Set("variables", string(variablesBz))
+	values.Set("features", string(apiUserProfileFeaturesBz))
+	req.URL.RawQuery = values.Encode()
+
+	req = req.WithContext(ctx)
+	res, err := crawler.doRequest(apiCallUsersByRestIDs, req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, &StatusError{StatusCode: res.StatusCode}
+	}
+
+	respBz, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var respObj UsersResponse
+	err = json.Unmarshal(respBz, &respObj)
+	if err != nil {
+		return nil, err
+	}
 
-		saveBz, _ := io.ReadAll(save)
-		go client.handleResponse(statusCode, header, saveBz)
+	if len(respObj.Errors) > 0 {
+		return nil, &APIError{Errors: respObj.Errors}
+	}
+
+	users := make([]User, 0, len(respObj.Data.Users))
+	for _, u := range respObj.Data.Users {
+		// unavailable users have no rest_id to tell which one they are
+		if u.Result.Typename != "User" || u.Result.RestID == "" {
+			continue
+		}
+		users = append(users, newUser(u.Result))
+	}
+
+	crawler.metrics.observeItems("users", len(users))
+	return users, nil
+}
+
+func newUser(result UserResult) User {
+	return User{
+		ID:              result.RestID,
+		ScreenName:      result.Legacy.ScreenName,
+		Name:            result.Legacy.Name,
+		Description:     result.Legacy.Description,
+		Location:        result.Legacy.Location,
+		CreatedAt:       result.Legacy.CreatedAt,
+		FollowersCount:  result.Legacy.FollowersCount,
+		FollowingCount:  result.Legacy.FriendsCount,
+		StatusesCount:   result.Legacy.StatusesCount,
+		Verified:        result.Legacy.Verified,
+		BlueVerified:    result.IsBlueVerified,
+		Protected:       result.Legacy.Protected,
+		ProfileImageURL: result.Legacy.ProfileImageURLHTTPS,
+	}
+}
+
+// APICalls returns the names of the API calls the crawler makes, each with its own account pool.
+func APICalls() []string {
+	return sortedKeys(apis)
+}
+
+// QueueDepths returns the number of requests waiting for a client, per API call.
+func (crawler *Crawler) QueueDepths() map[string]QueueDepth {
+	depths := make(map[string]QueueDepth, len(crawler.schedulers))
+	for apiName, s := range crawler.schedulers {
+		depths[apiName] = s.QueueDepth()
+	}
+
+	return depths
+}
+
+func (crawler *Crawler) apiURL(call string) string {
+	return crawler.hosts.Web + apis[call].Path
+}
+
+func (crawler *Crawler) apiClients(call string) []*Client {
+	crawler.mtx.RLock()
+	defer crawler.mtx.RUnlock()
+
+	clients := make([]*Client, 0, len(crawler.clients[call]))
+	for _, client := range crawler.clients[call] {
+		clients = append(clients, client)
+	}
+
+	return clients
+}
+
+func (crawler *Crawler) doRequest(call string, req *http.Request) (*http.Response, error) {
+	client, err := crawler.schedulers[call].acquire(req.Context(), crawler.waitForCapacity)
+	if errors.Is(err, ErrRateLimited) {
+		crawler.metrics.observeRateLimited(call)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	logger := contextLogger(req.Context(), crawler.logger).With("api", call, "account", client.baseClient.Username)
+
+	start := time.Now()
+	resp, err := client.baseClient.DoRequestWithAuth(req)
+	if err != nil {
+		crawler.metrics.observeRequest(call, client.baseClient.Username, 0, time.Since(start))
+		logger.Warn("request failed", "error", err)
+		client.release()
+		return nil, err
+	}
+	crawler.metrics.observeRequest(call, client.baseClient.Username, resp.StatusCode, time.Since(start))
+
+	level := slog.LevelDebug
+	if resp.StatusCode != http.StatusOK {
+		level = slog.LevelWarn
+	}
+	logger.Log(req.Context(), level, "twitter responded", "status", resp.StatusCode, "duration", time.Since(start))
+
+	statusCode := resp.StatusCode
+	header := http.Header{}
+	for headerName, headerValues := range resp.Header {
+		for _, headerValue := range headerValues {
+			header.Add(headerName, headerValue)
+		}
+	}
+
+	var save io.ReadCloser
+	if resp.Body != nil && resp.Body != http.NoBody {
+		save, resp.Body, err = drainBody(resp.Body)
+		if err != nil {
+			client.release()
+			return nil, err
+		}
+	}
+
+	saveBz, _ := io.ReadAll(save)
+	go client.handleResponse(statusCode, header, saveBz)
+
+	return resp, err
+}
 
-		return resp, err
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
 	}
+	sort.Strings(keys)
 
-	return nil, &rateLimitError{Reset: retryAfterInt64}
+	return keys
 }
 
 func drainBody(b io.ReadCloser) (r1, r2 io.ReadCloser, err error) {
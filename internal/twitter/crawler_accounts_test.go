@@ -0,0 +1,103 @@
+package twitter
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hiendaovinh/toolkit/pkg/arr"
+	"github.com/phinc275/teatweet/internal/twitter/twittertest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCrawlerRemoveAccount(t *testing.T) {
+	crawler, server := newTestCrawler(t, time.Now().Add(time.Hour).Unix())
+
+	credentials := crawler.Credentials()
+	assert.Len(t, credentials, 1)
+	assert.Equal(t, "test", credentials[0].Username)
+
+	assert.NoError(t, crawler.RemoveAccount("test"))
+	assert.Empty(t, crawler.Credentials())
+	assert.True(t, errors.Is(crawler.RemoveAccount("test"), ErrAccountNotFound))
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	_, err := crawler.doRequest(apiCallFavoriters, req)
+	assert.True(t, errors.Is(err, ErrNoClient))
+}
+
+func TestCrawlerRemoveAccountWhileRequesting(t *testing.T) {
+	crawler, server := newTestCrawler(t, time.Now().Add(time.Hour).Unix())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+			_, _ = crawler.doRequest(apiCallFavoriters, req)
+		}
+	}()
+
+	assert.NoError(t, crawler.RemoveAccount("test"))
+	<-done
+}
+
+func TestCrawlerSyncCredentials(t *testing.T) {
+	fake := twittertest.NewServer(t)
+	fake.AddAccount("alice", "secret")
+	fake.AddAccount("bob", "secret")
+	fake.AddAccount("carol", "secret")
+	crawler := newFakeCrawler(t, fake, []Credential{{Username: "alice", Password: "secret"}})
+
+	// accounts added over the admin API are kept, those of the credentials file follow it
+	assert.NoError(t, crawler.AddCredential(contextWithTimeout(t), Credential{Username: "bob", Password: "secret"}))
+	crawler.SyncCredentials(contextWithTimeout(t), []Credential{{Username: "carol", Password: "secret"}})
+	assert.Equal(t, []string{"bob", "carol"}, arr.ArrMap(crawler.Credentials(), func(credential Credential) string { return credential.Username }))
+
+	crawler.SyncCredentials(contextWithTimeout(t), nil)
+	assert.Equal(t, []string{"bob"}, arr.ArrMap(crawler.Credentials(), func(credential Credential) string { return credential.Username }))
+}
+
+func TestCrawlerAccounts(t *testing.T) {
+	crawler, _ := newTestCrawler(t, time.Now().Add(time.Hour).Unix())
+	client := crawler.clients[apiCallFavoriters]["test"]
+
+	client.handleResponse(http.StatusForbidden, http.Header{}, nil)
+
+	statuses := crawler.Accounts()[apiCallFavoriters]
+	assert.Len(t, statuses, 1)
+	assert.Equal(t, AccountConnected, statuses[0].State)
+	assert.True(t, statuses[0].Forbidden)
+	assert.Equal(t, "unexpected response code 403", statuses[0].LastError)
+	assert.Empty(t, crawler.Accounts()[apiCallFollowing])
+
+	assert.NoError(t, crawler.ClearForbidden("test", ""))
+	assert.NoError(t, crawler.SetAccountDisabled("test", true))
+
+	statuses = crawler.Accounts()[apiCallFavoriters]
+	assert.False(t, statuses[0].Forbidden)
+	assert.True(t, statuses[0].Disabled)
+
+	assert.True(t, errors.Is(crawler.SetAccountDisabled("nobody", true), ErrAccountNotFound))
+}
+
+func TestCrawlerClose(t *testing.T) {
+	store, err := NewFileSessionStore(t.TempDir())
+	assert.NoError(t, err)
+
+	crawler, _ := newTestCrawler(t, time.Now().Add(time.Hour).Unix())
+	client := crawler.clients[apiCallFavoriters]["test"]
+	client.baseClient.sessionStore = store
+
+	assert.NoError(t, crawler.Close())
+
+	session, err := store.Load("test")
+	assert.NoError(t, err)
+	assert.Equal(t, "test", session.Username)
+
+	// nothing is started anymore
+	client.baseClient.Password = "p"
+	client.reconnector.trigger()
+	assert.False(t, client.reconnector.status().Running)
+}
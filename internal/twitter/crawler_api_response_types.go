@@ -39,14 +39,20 @@ type TweetUserLegacy struct {
 }
 
 type TweetResults struct {
-	Result struct {
-		RestID string `json:"rest_id"`
-		Core   struct {
-			UserResults UserResults `json:"user_results"`
-		} `json:"core"`
-		Legacy TweetResultLegacy `json:"legacy"`
-		Views  TweetResultViews  `json:"views"`
-	} `json:"result"`
+	Result TweetResult `json:"result"`
+}
+
+type TweetResult struct {
+	Typename string `json:"__typename"`
+	RestID   string `json:"rest_id"`
+	Core     struct {
+		UserResults UserResults `json:"user_results"`
+	} `json:"core"`
+	Legacy             TweetResultLegacy `json:"legacy"`
+	Views              TweetResultViews  `json:"views"`
+	QuotedStatusResult *TweetResults     `json:"quoted_status_result"` // nullable
+	// Tweet is the actual tweet of a TweetWithVisibilityResults
+	Tweet *TweetResult `json:"tweet"` // nullable
 }
 
 type TweetResultViews struct {
@@ -85,9 +91,11 @@ type TweetResultLegacy struct {
 		Symbols      []Symbol      `json:"symbols"`
 		URLs         []URL         `json:"urls"`
 	} `json:"entities"`
-	FullText             string `json:"full_text"`
-	InReplyToStatusIDStr string `json:"in_reply_to_status_id_str"`
-	QuotedStatusIDStr    string `json:"quoted_status_id_str"`
+	FullText              string        `json:"full_text"`
+	ConversationIDStr     string        `json:"conversation_id_str"`
+	InReplyToStatusIDStr  string        `json:"in_reply_to_status_id_str"`
+	QuotedStatusIDStr     string        `json:"quoted_status_id_str"`
+	RetweetedStatusResult *TweetResults `json:"retweeted_status_result"` // nullable
 
 	FavoriteCount int64 `json:"favorite_count"`
 	QuoteCount    int64 `json:"quote_count"`
@@ -171,10 +179,13 @@ type FavoritersResponse struct {
 	Errors []Error `json:"errors"`
 }
 
+// FollowingResponse is response from Following and Followers APIs
 type FollowingResponse struct {
 	Data struct {
 		User struct {
 			Result struct {
+				Typename string `json:"__typename"`
+				Reason   string `json:"reason"`
 				Timeline struct {
 					Timeline struct {
 						Instructions []FollowingInstruction `json:"instructions"`
@@ -186,6 +197,87 @@ type FollowingResponse struct {
 	Errors []Error `json:"errors"`
 }
 
+// TweetDetailResponse is response from TweetDetail API
+// count: about 30 threads
+// rate limit 150 per 15 minutes
+type TweetDetailResponse struct {
+	Data struct {
+		ThreadedConversationWithInjectionsV2 struct {
+			Instructions []TweetDetailInstruction `json:"instructions"`
+		} `json:"threaded_conversation_with_injections_v2"`
+	} `json:"data"`
+	Errors []Error `json:"errors"`
+}
+
+// UserResponse is response from UserByScreenName and UserByRestID APIs
+// rate limit 95 (UserByScreenName) and 500 (UserByRestID) per 15 minutes
+type UserResponse struct {
+	Data struct {
+		User struct {
+			Result UserResult `json:"result"`
+		} `json:"user"`
+	} `json:"data"`
+	Errors []Error `json:"errors"`
+}
+
+// UsersResponse is response from UsersByRestIds API
+// count: up to 100 users
+// rate limit 500 per 15 minutes
+type UsersResponse struct {
+	Data struct {
+		Users []struct {
+			Result UserResult `json:"result"`
+		} `json:"users"`
+	} `json:"data"`
+	Errors []Error `json:"errors"`
+}
+
+type UserResult struct {
+	Typename       string           `json:"__typename"`
+	Reason         string           `json:"reason"`
+	RestID         string           `json:"rest_id"`
+	IsBlueVerified bool             `json:"is_blue_verified"`
+	Legacy         UserResultLegacy `json:"legacy"`
+}
+
+type UserResultLegacy struct {
+	CreatedAt            time.Time `json:"created_at"`
+	Description          string    `json:"description"`
+	Location             string    `json:"location"`
+	Name                 string    `json:"name"`
+	ScreenName           string    `json:"screen_name"`
+	FollowersCount       int64     `json:"followers_count"`
+	FriendsCount         int64     `json:"friends_count"`
+	StatusesCount        int64     `json:"statuses_count"`
+	Verified             bool      `json:"verified"`
+	Protected            bool      `json:"protected"`
+	ProfileImageURLHTTPS string    `json:"profile_image_url_https"`
+}
+
+func (obj *UserResultLegacy) UnmarshalJSON(data []byte) error {
+	type Alias UserResultLegacy
+	aux := &struct {
+		*Alias
+		CreatedAt string `json:"created_at"`
+	}{
+		Alias: (*Alias)(obj),
+	}
+
+	if err := json.Unmarshal(data, aux); err != nil {
+		return err
+	}
+
+	if aux.CreatedAt != "" {
+		createdAt, err := time.Parse(time.RubyDate, aux.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("cannot unmarshal %s into a time.Time (%s)", aux.CreatedAt, time.RubyDate)
+		}
+		obj.CreatedAt = createdAt
+	}
+
+	return nil
+}
+
 // ========= Instructions
 
 type Instruction[T any] struct {
@@ -198,9 +290,10 @@ type (
 		Instruction[SearchTimelineEntry]
 		Entry *InstructionEntry `json:"entry"`
 	}
-	RetweetersInstruction Instruction[RetweetersEntry]
-	FavoritersInstruction Instruction[FavoritersEntry]
-	FollowingInstruction  Instruction[FollowingEntry]
+	RetweetersInstruction  Instruction[RetweetersEntry]
+	FavoritersInstruction  Instruction[FavoritersEntry]
+	FollowingInstruction   Instruction[FollowingEntry]
+	TweetDetailInstruction Instruction[TweetDetailEntry]
 )
 
 // ========= Entries
@@ -218,6 +311,7 @@ type (
 	RetweetersEntry     Entry[RetweetersEntryContent]
 	FavoritersEntry     Entry[FavoritersEntryContent]
 	FollowingEntry      Entry[FollowingEntryContent]
+	TweetDetailEntry    Entry[TweetDetailEntryContent]
 )
 
 func (obj *SearchTimelineEntry) UnmarshalJSON(data []byte) error {
@@ -308,6 +402,28 @@ func (obj *FollowingEntry) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+func (obj *TweetDetailEntry) UnmarshalJSON(data []byte) error {
+	type Alias TweetDetailEntry
+	aux := &struct {
+		*Alias
+		SortIndex string `json:"sortIndex"`
+	}{
+		Alias: (*Alias)(obj),
+	}
+
+	if err := json.Unmarshal(data, aux); err != nil {
+		return err
+	}
+
+	sortIndex, err := strconv.ParseInt(aux.SortIndex, 10, 64)
+	if err != nil {
+		return fmt.Errorf("cannot unmarshal %s into an int64", aux.SortIndex)
+	}
+	obj.SortIndex = sortIndex
+
+	return nil
+}
+
 // ========= EntryContent
 
 type EmptyEntryContent struct {
@@ -327,6 +443,18 @@ type (
 	RetweetersEntryContent     EntryContent[RetweetersEntryItemContent]
 	FavoritersEntryContent     EntryContent[FavoritersEntryItemContent]
 	FollowingEntryContent      EntryContent[FollowingEntryItemContent]
+	// TweetDetailEntryContent is either a single item (the focal tweet, its ancestors or a cursor)
+	// or a conversation module, i.e. a thread of replies
+	TweetDetailEntryContent struct {
+		EntryContent[TweetDetailEntryItemContent]
+		Items []TweetDetailModuleItem `json:"items"`
+	}
+	TweetDetailModuleItem struct {
+		EntryID string `json:"entryId"`
+		Item    struct {
+			ItemContent *TweetDetailEntryItemContent `json:"itemContent"` // nullable
+		} `json:"item"`
+	}
 )
 
 // ========= EntryContentItem
@@ -350,3 +478,11 @@ type FollowingEntryItemContent struct {
 	ItemType    string      `json:"itemType"`
 	UserResults UserResults `json:"user_results"`
 }
+
+// TweetDetailEntryItemContent is either a tweet or a cursor
+type TweetDetailEntryItemContent struct {
+	ItemType     string       `json:"itemType"`
+	TweetResults TweetResults `json:"tweet_results"`
+	CursorType   string       `json:"cursorType"`
+	Value        string       `json:"value"`
+}
@@ -0,0 +1,189 @@
+package twitter
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phinc275/teatweet/internal/twitter/twittertest"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	updateGolden    = flag.Bool("update", false, "rewrite the golden files of TestCrawlerGolden")
+	recordCassettes = flag.Bool("record", false, "record the cassette of TestCrawlerGolden again, against the live Twitter if TWITTER_USERNAME and TWITTER_PASSWORD are set")
+)
+
+const (
+	goldenCassetteDir = "testdata/cassettes/crawl"
+	goldenTweetID     = "100"
+	goldenUserID      = "1000"
+	goldenScreenName  = "alice"
+)
+
+var goldenUserIDs = []string{"201", "203", "666"}
+
+// TestCrawlerGolden replays the cassette in testdata/cassettes/crawl and compares what is crawled with testdata/golden.
+// To catch up with Twitter, set goldenTweetID, goldenUserID, goldenScreenName and goldenUserIDs to live ones and run it with -record -update.
+func TestCrawlerGolden(t *testing.T) {
+	credential := Credential{Username: "golden", Password: "golden-secret"}
+	opts := make([]Option, 0)
+
+	if *recordCassettes {
+		assert.NoError(t, os.RemoveAll(goldenCassetteDir))
+
+		var transport http.RoundTripper = http.DefaultTransport
+		if username := os.Getenv("TWITTER_USERNAME"); username != "" {
+			credential = Credential{Username: username, Password: os.Getenv("TWITTER_PASSWORD")}
+		} else {
+			fake := twittertest.NewServer(t)
+			fake.AddAccount(credential.Username, credential.Password)
+			transport = fake.Client().Transport
+			opts = append(opts, WithHosts(fakeHosts(fake)))
+		}
+
+		cassette, err := NewCassette(goldenCassetteDir, CassetteRecord, transport)
+		assert.NoError(t, err)
+		opts = append(opts, WithTransport(cassette))
+	} else {
+		cassette, err := NewCassette(goldenCassetteDir, CassetteReplay, nil)
+		assert.NoError(t, err)
+		opts = append(opts, WithTransport(cassette))
+	}
+
+	crawler, err := NewCrawler([]Credential{credential}, opts...)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = crawler.Close() })
+
+	assertGolden(t, "replies", crawlAll(t, crawler.Replies, goldenTweetID))
+	assertGolden(t, "quotes", crawlAll(t, crawler.Quotes, goldenTweetID))
+	assertGolden(t, "retweets", crawlAll(t, crawler.Retweets, goldenTweetID))
+	assertGolden(t, "likes", crawlAll(t, crawler.Likes, goldenTweetID))
+	assertGolden(t, "following", crawlAll(t, crawler.Following, goldenUserID))
+	assertGolden(t, "followers", crawlAll(t, crawler.Followers, goldenUserID))
+
+	conversation, _, err := crawler.TweetDetail(contextWithTimeout(t), goldenTweetID, "")
+	assert.NoError(t, err)
+	assertGolden(t, "conversation", conversation)
+
+	user, err := crawler.UserByScreenName(contextWithTimeout(t), goldenScreenName)
+	assert.NoError(t, err)
+	assertGolden(t, "user", user)
+
+	users, failed, err := crawler.UsersByRestIDs(contextWithTimeout(t), goldenUserIDs)
+	assert.NoError(t, err)
+	assert.Empty(t, failed)
+	assertGolden(t, "users", users)
+
+	if *recordCassettes {
+		files, _ := filepath.Glob(filepath.Join(goldenCassetteDir, "*.json"))
+		for _, file := range files {
+			bz, _ := os.ReadFile(file)
+			assert.NotContains(t, string(bz), credential.Password, file)
+		}
+	}
+}
+
+func TestCassetteScrubbing(t *testing.T) {
+	fake := twittertest.NewServer(t)
+	fake.AddAccount("alice", "hunter2")
+
+	dir := t.TempDir()
+	cassette, err := NewCassette(dir, CassetteRecord, fake.Client().Transport)
+	assert.NoError(t, err)
+	crawler := newFakeCrawler(t, fake, []Credential{{Username: "alice", Password: "hunter2"}}, WithTransport(cassette))
+
+	recorded, _, err := crawler.Likes(contextWithTimeout(t), goldenTweetID, "")
+	assert.NoError(t, err)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	assert.NotEmpty(t, files)
+	for _, file := range files {
+		bz, _ := os.ReadFile(file)
+		assert.NotContains(t, string(bz), "hunter2", file)
+		assert.NotContains(t, string(bz), "auth-alice", file)
+		assert.NotContains(t, string(bz), "csrf-", file)
+	}
+
+	cassette, err = NewCassette(dir, CassetteReplay, nil)
+	assert.NoError(t, err)
+	replayer, err := NewCrawler([]Credential{{Username: "alice", Password: "anything"}}, WithTransport(cassette))
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = replayer.Close() })
+
+	replayed, _, err := replayer.Likes(contextWithTimeout(t), goldenTweetID, "")
+	assert.NoError(t, err)
+	assert.Equal(t, recorded, replayed)
+
+	_, _, err = replayer.Likes(contextWithTimeout(t), "404", "")
+	assert.ErrorContains(t, err, "no request recorded")
+}
+
+func TestCassetteRecordingIsStable(t *testing.T) {
+	record := func() map[string]string {
+		fake := twittertest.NewServer(t)
+		fake.AddAccount("alice", "hunter2")
+
+		dir := t.TempDir()
+		cassette, err := NewCassette(dir, CassetteRecord, fake.Client().Transport)
+		assert.NoError(t, err)
+		crawler := newFakeCrawler(t, fake, []Credential{{Username: "alice", Password: "hunter2"}}, WithTransport(cassette))
+		crawlAll(t, crawler.Likes, goldenTweetID)
+
+		files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+		contents := make(map[string]string, len(files))
+		for _, file := range files {
+			bz, _ := os.ReadFile(file)
+			contents[filepath.Base(file)] = string(bz)
+		}
+		return contents
+	}
+
+	// on another port, a second later
+	first := record()
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	assert.Equal(t, first, record())
+}
+
+func TestCassetteScrubbingLoginInputs(t *testing.T) {
+	body := scrubBody([]byte(`{"flow_token":"f","subtask_inputs":[` +
+		`{"subtask_id":"LoginEnterAlternateIdentifierSubtask","enter_text":{"text":"u@example.com","link":"next_link"}},` +
+		`{"subtask_id":"LoginTwoFactorAuthChallenge","enter_text":{"text":"123456","link":"next_link"}}]}`))
+	assert.NotContains(t, string(body), "u@example.com")
+	assert.NotContains(t, string(body), "123456")
+	assert.Contains(t, string(body), "next_link")
+
+	header := scrubHeader(http.Header{
+		"Cookie":     {"auth_token=a; twid=u%3D1000"},
+		"Set-Cookie": {"twid=u%3D1000; Path=/; Secure"},
+	})
+	assert.Equal(t, "auth_token=REDACTED; twid=REDACTED", header.Get("Cookie"))
+	assert.Equal(t, "twid=REDACTED; Path=/; Secure", header.Get("Set-Cookie"))
+
+	assert.Equal(t, "https://twitter.com/i/api/1.1/strato/column/User/REDACTED/search/searchSafety",
+		scrubURL("https://twitter.com/i/api/1.1/strato/column/User/1000/search/searchSafety"))
+}
+
+func assertGolden(t *testing.T, name string, v interface{}) {
+	t.Helper()
+
+	bz, err := json.MarshalIndent(v, "", "  ")
+	assert.NoError(t, err)
+	bz = append(bz, '\n')
+
+	path := filepath.Join("testdata", "golden", name+".json")
+	if *updateGolden {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		assert.NoError(t, os.WriteFile(path, bz, 0o644))
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, strings.TrimSpace(string(want)), strings.TrimSpace(string(bz)), "%s differs from %s, run with -update if expected", name, path)
+}
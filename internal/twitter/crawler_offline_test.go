@@ -0,0 +1,312 @@
+package twitter
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hiendaovinh/toolkit/pkg/arr"
+	"github.com/phinc275/teatweet/internal/twitter/twittertest"
+	"github.com/stretchr/testify/assert"
+)
+
+func crawlAll[T any](t *testing.T, fetch func(ctx context.Context, id string, cursor string) ([]T, string, error), id string) []T {
+	items := make([]T, 0)
+	cursor := ""
+	for {
+		crawled, nextCursor, err := fetch(contextWithTimeout(t), id, cursor)
+		if !assert.NoError(t, err) {
+			return items
+		}
+
+		items = append(items, crawled...)
+		if nextCursor == "" {
+			return items
+		}
+		cursor = nextCursor
+	}
+}
+
+func TestCrawlerOffline(t *testing.T) {
+	fake := twittertest.NewServer(t)
+	fake.AddAccount("alice", "secret")
+	crawler := newFakeCrawler(t, fake, []Credential{{Username: "alice", Password: "secret"}})
+	assert.Equal(t, 1, fake.LoginCount("alice"))
+
+	replies := crawlAll(t, crawler.Replies, "100")
+	assert.Equal(t, []string{"201", "203", "204"}, arr.ArrMap(replies, func(reply Reply) string { return reply.UserID }))
+	assert.Equal(t, "gm $BTC #Crypto example.com", replies[0].NormalizedText)
+	assert.Equal(t, []string{"crypto"}, replies[0].LoweredHashtags)
+	assert.Equal(t, []string{"BTC"}, replies[0].Symbols)
+	assert.Equal(t, time.Date(2023, time.September, 19, 8, 0, 0, 0, time.UTC), replies[0].CreatedAt.UTC())
+
+	quotes := crawlAll(t, crawler.Quotes, "100")
+	assert.Equal(t, []string{"202"}, arr.ArrMap(quotes, func(quote Quote) string { return quote.UserID }))
+
+	retweets := crawlAll(t, crawler.Retweets, "100")
+	assert.Equal(t, []string{"201", "202", "203"}, arr.ArrMap(retweets, func(retweet Retweet) string { return retweet.UserID }))
+
+	// the user of the second like is only known by its entry ID
+	likes := crawlAll(t, crawler.Likes, "100")
+	assert.Equal(t, []string{"201", "205"}, arr.ArrMap(likes, func(like Like) string { return like.UserID }))
+
+	followings := crawlAll(t, crawler.Following, "1000")
+	assert.Equal(t, []string{"bob", "carol", "dave"}, arr.ArrMap(followings, func(following Following) string { return following.ScreenName }))
+
+	followers := crawlAll(t, crawler.Followers, "1000")
+	assert.Equal(t, []string{"erin", "frank", "dave"}, arr.ArrMap(followers, func(follower Follower) string { return follower.ScreenName }))
+}
+
+func TestCrawlerOfflineLogin(t *testing.T) {
+	fake := twittertest.NewServer(t)
+	fake.AddAccount("alice", "secret")
+	crawler := newFakeCrawler(t, fake, nil)
+
+	err := crawler.AddCredential(contextWithTimeout(t), Credential{Username: "alice", Password: "wrong"})
+	var subtaskErr *LoginSubtaskError
+	assert.ErrorAs(t, err, &subtaskErr)
+	assert.Equal(t, "LoginEnterPassword", subtaskErr.SubtaskID)
+	var apiErr *APIError
+	assert.ErrorAs(t, err, &apiErr)
+
+	// falls back to guest/activate.json if the guest token is not in the home page
+	fake.FailNext("home", http.StatusServiceUnavailable)
+	err = crawler.AddCredential(contextWithTimeout(t), Credential{Username: "alice", Password: "secret"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fake.LoginCount("alice"))
+}
+
+func TestCrawlerOfflineRelogin(t *testing.T) {
+	fake := twittertest.NewServer(t)
+	fake.AddAccount("alice", "secret")
+	crawler := newFakeCrawler(t, fake, []Credential{{Username: "alice", Password: "secret"}})
+
+	// a failed forced login is retried by the reconnect loop
+	fake.FailNext("task", http.StatusServiceUnavailable)
+	assert.Error(t, crawler.Relogin(contextWithTimeout(t), "alice"))
+	assert.Eventually(t, func() bool {
+		return crawler.Accounts()[apiCallRetweeters][0].State == AccountConnected
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 2, fake.LoginCount("alice"))
+	assert.False(t, crawler.Accounts()[apiCallRetweeters][0].LastLoginAt.IsZero())
+
+	assert.ErrorIs(t, crawler.Relogin(contextWithTimeout(t), "nobody"), ErrAccountNotFound)
+}
+
+func TestCrawlerOfflineRateLimited(t *testing.T) {
+	fake := twittertest.NewServer(t)
+	fake.AddAccount("alice", "secret")
+	fake.SetRateLimit("Retweeters", 1, time.Now().Add(time.Hour))
+	crawler := newFakeCrawler(t, fake, []Credential{{Username: "alice", Password: "secret"}})
+
+	// the single request left is used up when the crawler checks the limits
+	_, _, err := crawler.Retweets(contextWithTimeout(t), "100", "")
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.Equal(t, 1, fake.RequestCount("Retweeters"))
+
+	_, _, err = crawler.Likes(contextWithTimeout(t), "100", "")
+	assert.NoError(t, err)
+}
+
+func TestCrawlerOfflineUnauthorized(t *testing.T) {
+	fake := twittertest.NewServer(t)
+	fake.AddAccount("alice", "secret")
+	crawler := newFakeCrawler(t, fake, []Credential{{Username: "alice", Password: "secret"}})
+
+	fake.ExpireSessions()
+	_, _, err := crawler.Retweets(contextWithTimeout(t), "100", "")
+	assert.Equal(t, &StatusError{StatusCode: http.StatusUnauthorized}, err)
+
+	// the account logs in again in the background
+	assert.Eventually(t, func() bool {
+		_, _, err := crawler.Retweets(contextWithTimeout(t), "100", "")
+		return err == nil
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.Equal(t, 2, fake.LoginCount("alice"))
+}
+
+func TestCrawlerOfflineReconnectGivesUp(t *testing.T) {
+	fake := twittertest.NewServer(t)
+	fake.AddAccount("alice", "secret")
+	crawler := newFakeCrawler(t, fake, []Credential{{Username: "alice", Password: "secret"}})
+
+	fake.ExpireSessions()
+	fake.FailNext("task", http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+	_, _, err := crawler.Retweets(contextWithTimeout(t), "100", "")
+	assert.Equal(t, &StatusError{StatusCode: http.StatusUnauthorized}, err)
+
+	// the reconnect loop is reported until it gives up
+	assert.Eventually(t, func() bool {
+		status := crawler.Accounts()[apiCallRetweeters][0]
+		return !status.Reconnect.Running && status.Reconnect.Attempts == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	status := crawler.Accounts()[apiCallRetweeters][0]
+	assert.Equal(t, AccountDisconnected, status.State)
+	assert.NotEmpty(t, status.Reconnect.LastError)
+	assert.True(t, status.Reconnect.NextAttemptAt.IsZero())
+}
+
+func TestCrawlerOfflineForbidden(t *testing.T) {
+	fake := twittertest.NewServer(t)
+	fake.AddAccount("alice", "secret")
+	crawler := newFakeCrawler(t, fake, []Credential{{Username: "alice", Password: "secret"}})
+
+	fake.FailNext("Favoriters", http.StatusForbidden)
+	_, _, err := crawler.Likes(contextWithTimeout(t), "100", "")
+	assert.Equal(t, &StatusError{StatusCode: http.StatusForbidden}, err)
+
+	assert.Eventually(t, func() bool {
+		return crawler.Accounts()[apiCallFavoriters][0].Forbidden
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, _, err = crawler.Likes(contextWithTimeout(t), "100", "")
+	assert.ErrorIs(t, err, ErrNoClient)
+
+	// other API calls of the account are not affected
+	_, _, err = crawler.Retweets(contextWithTimeout(t), "100", "")
+	assert.NoError(t, err)
+}
+
+func TestCrawlerOfflineUsers(t *testing.T) {
+	fake := twittertest.NewServer(t)
+	fake.AddAccount("alice", "secret")
+	crawler := newFakeCrawler(t, fake, []Credential{{Username: "alice", Password: "secret"}})
+
+	user, err := crawler.UserByScreenName(contextWithTimeout(t), "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, &User{
+		ID:              "201",
+		ScreenName:      "alice",
+		Name:            "Alice",
+		Description:     "gm, building on-chain",
+		Location:        "Lisbon",
+		CreatedAt:       time.Date(2009, time.June, 2, 20, 12, 29, 0, time.UTC),
+		FollowersCount:  1200,
+		FollowingCount:  321,
+		StatusesCount:   4567,
+		BlueVerified:    true,
+		ProfileImageURL: "https://pbs.twimg.com/profile_images/201/avatar_normal.jpg",
+	}, withUTCCreatedAt(user))
+
+	user, err = crawler.UserByRestID(contextWithTimeout(t), "203")
+	assert.NoError(t, err)
+	assert.Equal(t, "carol", user.ScreenName)
+	assert.True(t, user.Protected)
+
+	user, err = crawler.UserByScreenName(contextWithTimeout(t), "mallory")
+	assert.NoError(t, err)
+	assert.Equal(t, &User{ScreenName: "mallory", Suspended: true}, user)
+
+	user, err = crawler.UserByRestID(contextWithTimeout(t), "666")
+	assert.NoError(t, err)
+	assert.Equal(t, &User{ID: "666", Suspended: true}, user)
+
+	_, err = crawler.UserByScreenName(contextWithTimeout(t), "nobody")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
+
+func TestCrawlerOfflineUsersByRestIDs(t *testing.T) {
+	fake := twittertest.NewServer(t)
+	fake.AddAccount("alice", "secret")
+	crawler := newFakeCrawler(t, fake, []Credential{{Username: "alice", Password: "secret"}})
+
+	ids := []string{"203", "666", "201", "203"}
+	for i := 0; i < 2*maxUsersPerLookup; i++ {
+		ids = append(ids, strconv.Itoa(5000+i))
+	}
+
+	// suspended and unknown users are left out, duplicates are looked up once
+	requests := fake.RequestCount("UsersByRestIds")
+	users, failed, err := crawler.UsersByRestIDs(contextWithTimeout(t), ids)
+	assert.NoError(t, err)
+	assert.Empty(t, failed)
+	assert.Equal(t, []string{"carol", "alice"}, arr.ArrMap(users, func(user User) string { return user.ScreenName }))
+	assert.Equal(t, requests+3, fake.RequestCount("UsersByRestIds"))
+
+	users, failed, err = crawler.UsersByRestIDs(contextWithTimeout(t), nil)
+	assert.NoError(t, err)
+	assert.Empty(t, failed)
+	assert.Empty(t, users)
+
+	// chunks which fail are reported, users resolved by the others are still returned.
+	// Chunks are sent at once, so any of them can be the one which fails.
+	unique := arr.ArrUnique(ids)
+	chunks := [][]string{unique[:maxUsersPerLookup], unique[maxUsersPerLookup : 2*maxUsersPerLookup], unique[2*maxUsersPerLookup:]}
+	fake.FailNext("UsersByRestIds", http.StatusServiceUnavailable)
+	users, failed, err = crawler.UsersByRestIDs(contextWithTimeout(t), ids)
+	assert.NoError(t, err)
+	assert.Contains(t, chunks, failed)
+	if _, ok := arr.ArrFind(failed, "203"); ok {
+		assert.Empty(t, users)
+	} else {
+		assert.Equal(t, []string{"carol", "alice"}, arr.ArrMap(users, func(user User) string { return user.ScreenName }))
+	}
+
+	// the call fails only when every chunk does
+	fake.FailNext("UsersByRestIds", http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+	users, failed, err = crawler.UsersByRestIDs(contextWithTimeout(t), ids)
+	assert.Equal(t, &StatusError{StatusCode: http.StatusServiceUnavailable}, err)
+	assert.Nil(t, users)
+	assert.Nil(t, failed)
+}
+
+func TestCrawlerOfflineTweetDetail(t *testing.T) {
+	fake := twittertest.NewServer(t)
+	fake.AddAccount("alice", "secret")
+	crawler := newFakeCrawler(t, fake, []Credential{{Username: "alice", Password: "secret"}})
+
+	conversation, nextCursor, err := crawler.TweetDetail(contextWithTimeout(t), "100", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "more-threads", nextCursor)
+
+	// the focal tweet is limited, its ancestor is left out
+	tweet := conversation.Tweet
+	assert.Equal(t, "100", tweet.ID)
+	assert.Equal(t, "99", tweet.InReplyToStatusID)
+	assert.Equal(t, "alice", tweet.UserScreenName)
+	assert.Equal(t, "wagmi #ETH example.com", tweet.NormalizedText)
+	assert.Equal(t, []string{"ETH"}, tweet.Hashtags)
+	assert.Equal(t, int64(1234), tweet.ViewCount)
+	assert.Equal(t, int64(42), tweet.FavoriteCount)
+	assert.Equal(t, "90", tweet.QuotedStatus.ID)
+	assert.Equal(t, "bob", tweet.QuotedStatus.UserScreenName)
+	assert.Nil(t, tweet.RetweetedStatus)
+
+	// the thread of the deleted reply is left out
+	assert.Len(t, conversation.Threads, 1)
+	assert.Equal(t, []string{"101", "102"}, arr.ArrMap(conversation.Threads[0].Tweets, func(tweet Tweet) string { return tweet.ID }))
+	assert.Equal(t, "thread-101-more", conversation.Threads[0].Cursor)
+
+	more, nextCursor, err := crawler.TweetDetail(contextWithTimeout(t), "100", conversation.Threads[0].Cursor)
+	assert.NoError(t, err)
+	assert.Empty(t, nextCursor)
+	assert.Equal(t, "106", more.Threads[0].Tweets[0].ID)
+
+	// "show more replies" cursors
+	replyIDs := make([]string, 0)
+	for cursor := "more-threads"; cursor != ""; {
+		conversation, cursor, err = crawler.TweetDetail(contextWithTimeout(t), "100", cursor)
+		if !assert.NoError(t, err) {
+			break
+		}
+		assert.Nil(t, conversation.Tweet)
+		for _, thread := range conversation.Threads {
+			replyIDs = append(replyIDs, arr.ArrMap(thread.Tweets, func(tweet Tweet) string { return tweet.ID })...)
+		}
+	}
+	assert.Equal(t, []string{"104", "105"}, replyIDs)
+
+	_, _, err = crawler.TweetDetail(contextWithTimeout(t), "404", "")
+	assert.ErrorIs(t, err, ErrTweetNotFound)
+}
+
+func withUTCCreatedAt(user *User) *User {
+	if user != nil {
+		user.CreatedAt = user.CreatedAt.UTC()
+	}
+
+	return user
+}
@@ -10,7 +10,12 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// TestCrawler crawls the live Twitter, see crawler_offline_test.go for the offline tests.
 func TestCrawler(t *testing.T) {
+	if os.Getenv("TWITTER_USERNAME") == "" {
+		t.Skip("TWITTER_USERNAME and TWITTER_PASSWORD are not set")
+	}
+
 	credentials := []Credential{
 		{Username: os.Getenv("TWITTER_USERNAME"), Password: os.Getenv("TWITTER_PASSWORD")},
 	}
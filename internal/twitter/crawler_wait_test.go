@@ -0,0 +1,150 @@
+package twitter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestCrawler(t *testing.T, reset int64, opts ...Option) (*Crawler, *httptest.Server) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(server.Close)
+
+	baseClient, err := NewBaseClientFromCookies(context.Background(), "test", nil)
+	assert.NoError(t, err)
+
+	crawler, err := NewCrawler(nil, opts...)
+	assert.NoError(t, err)
+
+	client := &Client{
+		baseClient:  baseClient,
+		baseURL:     server.URL,
+		callLimit:   1,
+		remaining:   0,
+		reset:       reset,
+		capacity:    crawler.schedulers[apiCallFavoriters].capacity,
+		reconnector: newReconnector(crawler.ctx, baseClient, crawler.reconnectPolicy),
+		mtx:         &sync.Mutex{},
+	}
+	client.reconnector.clients = []*Client{client}
+	crawler.clients[apiCallFavoriters] = map[string]*Client{"test": client}
+
+	return crawler, server
+}
+
+func TestCrawlerDoRequestRateLimited(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Unix()
+	crawler, server := newTestCrawler(t, reset)
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	_, err := crawler.doRequest(apiCallFavoriters, req)
+
+	var rateLimitErr *RateLimitError
+	assert.True(t, errors.As(err, &rateLimitErr))
+	assert.Equal(t, reset, rateLimitErr.Reset)
+}
+
+func TestCrawlerDoRequestWaitForCapacity(t *testing.T) {
+	crawler, server := newTestCrawler(t, time.Now().Unix(), WithWaitForCapacity())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	resp, err := crawler.doRequest(apiCallFavoriters, req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// the only slot is now pending, a short deadline gives up with the rate limit error
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	crawler.clients[apiCallFavoriters]["test"].mtx.Lock()
+	crawler.clients[apiCallFavoriters]["test"].reset = time.Now().Add(time.Hour).Unix()
+	crawler.clients[apiCallFavoriters]["test"].mtx.Unlock()
+
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	_, err = crawler.doRequest(apiCallFavoriters, req)
+	assert.ErrorIs(t, err, ErrRateLimited)
+}
+
+// waitInQueue sends a request in the background and returns once it is waiting for a client.
+func waitInQueue(t *testing.T, ctx context.Context, crawler *Crawler, url string) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		resp, err := crawler.doRequest(apiCallFavoriters, req)
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		done <- err
+	}()
+
+	assert.Eventually(t, func() bool {
+		return crawler.QueueDepths()[apiCallFavoriters].Interactive == 1
+	}, 5*time.Second, time.Millisecond)
+	return done
+}
+
+func TestCrawlerDoRequestWaitForCapacityReleased(t *testing.T) {
+	crawler, server := newTestCrawler(t, time.Now().Add(-time.Minute).Unix(), WithWaitForCapacity())
+	client := crawler.clients[apiCallFavoriters]["test"]
+
+	// the only slot of the window is taken
+	ok, _ := client.isAvailable(0)
+	assert.True(t, ok)
+
+	done := waitInQueue(t, contextWithTimeout(t), crawler, server.URL)
+	client.release()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the waiting request is not served once the slot is released")
+	}
+}
+
+func TestCrawlerDoRequestWaitForCapacityReset(t *testing.T) {
+	crawler, server := newTestCrawler(t, time.Now().Add(time.Hour).Unix(), WithWaitForCapacity())
+	client := crawler.clients[apiCallFavoriters]["test"]
+
+	done := waitInQueue(t, contextWithTimeout(t), crawler, server.URL)
+
+	// the rate limit window resets
+	client.mtx.Lock()
+	client.reset = time.Now().Add(-time.Second).Unix()
+	client.mtx.Unlock()
+	client.capacity.broadcast()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the waiting request is not served once the rate limit resets")
+	}
+}
+
+func TestCrawlerDoRequestWaitForCapacityCanceled(t *testing.T) {
+	crawler, server := newTestCrawler(t, time.Now().Add(time.Hour).Unix(), WithWaitForCapacity())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := waitInQueue(t, ctx, crawler, server.URL)
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, ErrRateLimited)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the waiting request does not give up once its context is canceled")
+	}
+	assert.Equal(t, QueueDepth{}, crawler.QueueDepths()[apiCallFavoriters])
+}
@@ -1,14 +1,136 @@
 package twitter
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
+	"strings"
 	"time"
+
+	"github.com/hiendaovinh/toolkit/pkg/arr"
+)
+
+// Sentinel errors, use errors.Is to check for them.
+// RateLimitError, APIError, Error and StatusError match the relevant sentinel errors.
+var (
+	// ErrNoClient is returned when there is no connected client to serve an API call.
+	ErrNoClient = errors.New("no client available")
+	// ErrAccountNotFound is returned when an account is not in the pool.
+	ErrAccountNotFound = errors.New("account not found")
+
+	ErrRateLimited   = errors.New("rate limited")
+	ErrUnauthorized  = errors.New("authentication failed")
+	ErrSuspended     = errors.New("user is suspended")
+	ErrProtected     = errors.New("user is protected")
+	ErrUserNotFound  = errors.New("user not found")
+	ErrTweetNotFound = errors.New("tweet not found")
+	// ErrNotFound is returned when Twitter does not say what does not exist, e.g. "page does not exist".
+	ErrNotFound = errors.New("not found")
 )
 
-type rateLimitError struct {
+// RateLimitError is returned when every client of an API call is rate limited.
+type RateLimitError struct {
 	Reset int64
 }
 
-func (err *rateLimitError) Error() string {
+func (err *RateLimitError) Error() string {
 	return fmt.Sprintf("retry after %s", time.Unix(err.Reset, 0))
 }
+
+func (err *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// APIError is returned when Twitter responds with an errors payload.
+// errors.As(err, &e) with e of type *Error yields the first error of the payload.
+type APIError struct {
+	Errors []Error
+}
+
+func (err *APIError) Error() string {
+	return fmt.Sprintf("server returns error: %s", strings.Join(
+		arr.ArrMap(err.Errors, func(err Error) string { return err.Message }),
+		";",
+	))
+}
+
+func (err *APIError) Is(target error) bool {
+	for _, e := range err.Errors {
+		if e.Is(target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (err *APIError) As(target interface{}) bool {
+	if e, ok := target.(**Error); ok && len(err.Errors) > 0 {
+		*e = &err.Errors[0]
+		return true
+	}
+
+	return false
+}
+
+// https://developer.twitter.com/en/support/twitter-api/error-troubleshooting
+var errorCodes = map[int]error{
+	17:  ErrUserNotFound,
+	34:  ErrNotFound,
+	32:  ErrUnauthorized,
+	50:  ErrUserNotFound,
+	63:  ErrSuspended,
+	64:  ErrSuspended,
+	88:  ErrRateLimited,
+	89:  ErrUnauthorized,
+	144: ErrTweetNotFound,
+	179: ErrProtected,
+	215: ErrUnauthorized,
+	399: ErrUnauthorized,
+	421: ErrTweetNotFound,
+}
+
+func (e Error) Error() string {
+	return e.Message
+}
+
+func (e Error) Is(target error) bool {
+	if e.Name == "AuthorizationError" {
+		return target == ErrUnauthorized
+	}
+
+	sentinel, ok := errorCodes[e.Code]
+	return ok && target == sentinel
+}
+
+// StatusError is returned when Twitter responds with an unexpected status code.
+type StatusError struct {
+	StatusCode int
+}
+
+func (err *StatusError) Error() string {
+	return fmt.Sprintf("unexpected response code %d", err.StatusCode)
+}
+
+func (err *StatusError) Is(target error) bool {
+	switch err.StatusCode {
+	case http.StatusUnauthorized:
+		return target == ErrUnauthorized
+	case http.StatusTooManyRequests:
+		return target == ErrRateLimited
+	default:
+		return false
+	}
+}
+
+// userUnavailableError converts the reason of an UserUnavailable result into a sentinel error.
+func userUnavailableError(reason string) error {
+	switch reason {
+	case "Suspended":
+		return ErrSuspended
+	case "Protected":
+		return ErrProtected
+	default:
+		return ErrUserNotFound
+	}
+}
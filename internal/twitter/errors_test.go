@@ -0,0 +1,39 @@
+package twitter
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrors(t *testing.T) {
+	var err error = &RateLimitError{Reset: 1700000000}
+	assert.ErrorIs(t, fmt.Errorf("wrapped: %w", err), ErrRateLimited)
+
+	var rateLimitErr *RateLimitError
+	assert.True(t, errors.As(fmt.Errorf("wrapped: %w", err), &rateLimitErr))
+	assert.Equal(t, int64(1700000000), rateLimitErr.Reset)
+
+	err = &APIError{Errors: []Error{
+		{Message: "Sorry, that page does not exist", Code: 34},
+		{Message: "User has been suspended.", Name: "GenericError", Kind: "Operational", Code: 63},
+	}}
+	assert.ErrorIs(t, err, ErrSuspended)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NotErrorIs(t, err, ErrProtected)
+	assert.EqualError(t, err, "server returns error: Sorry, that page does not exist;User has been suspended.")
+
+	var twitterErr *Error
+	assert.True(t, errors.As(err, &twitterErr))
+	assert.Equal(t, 34, twitterErr.Code)
+
+	err = &APIError{Errors: []Error{{Name: "AuthorizationError", Kind: "Permissions"}}}
+	assert.ErrorIs(t, err, ErrUnauthorized)
+
+	assert.ErrorIs(t, &StatusError{StatusCode: http.StatusUnauthorized}, ErrUnauthorized)
+	assert.ErrorIs(t, &StatusError{StatusCode: http.StatusTooManyRequests}, ErrRateLimited)
+	assert.NotErrorIs(t, &StatusError{StatusCode: http.StatusBadGateway}, ErrUnauthorized)
+}
@@ -0,0 +1,29 @@
+package twitter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/phinc275/teatweet/internal/twitter/twittertest"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeHosts points a crawler at fake.
+func fakeHosts(fake *twittertest.Server) Hosts {
+	return Hosts{Web: fake.URL, API: fake.URL}
+}
+
+// newFakeCrawler returns a crawler logged in to fake with the given accounts.
+func newFakeCrawler(t *testing.T, fake *twittertest.Server, credentials []Credential, opts ...Option) *Crawler {
+	opts = append([]Option{
+		WithHosts(fakeHosts(fake)),
+		WithTransport(fake.Client().Transport),
+		WithReconnectPolicy(BackoffPolicy{Min: 10 * time.Millisecond, Max: 10 * time.Millisecond, MaxAttempts: 3}),
+	}, opts...)
+
+	crawler, err := NewCrawler(credentials, opts...)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = crawler.Close() })
+
+	return crawler
+}
@@ -0,0 +1,119 @@
+package twitter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// defaultProbePolicy re-probes forbidden clients for about a day before giving up.
+var defaultProbePolicy = BackoffPolicy{
+	Min:         time.Minute,
+	Max:         time.Hour,
+	MaxAttempts: 30,
+}
+
+// maxHealthCheckInterval bounds how late a due probe may run.
+const maxHealthCheckInterval = 5 * time.Second
+
+// checkForbidden re-probes forbidden clients until ctx is done, see probeForbidden.
+func (crawler *Crawler) checkForbidden(ctx context.Context) {
+	interval := crawler.probePolicy.Min
+	if interval <= 0 || interval > maxHealthCheckInterval {
+		interval = maxHealthCheckInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		wg := &sync.WaitGroup{}
+		for apiName := range apis {
+			for _, client := range crawler.apiClients(apiName) {
+				if !client.probeDue(crawler.probePolicy) {
+					continue
+				}
+
+				wg.Add(1)
+				go func(apiName string, client *Client) {
+					defer wg.Done()
+					client.probeForbidden(apiName, crawler.probePolicy)
+				}(apiName, client)
+			}
+		}
+		wg.Wait()
+	}
+}
+
+// probeDue reports whether a forbidden client should be probed now, scheduling its first probe if needed.
+func (client *Client) probeDue(policy BackoffPolicy) bool {
+	if !client.baseClient.Connected() {
+		return false
+	}
+
+	client.mtx.Lock()
+	defer client.mtx.Unlock()
+
+	if !client.forbidden || client.disabled || policy.exhausted(client.probeAttempts) {
+		return false
+	}
+
+	if client.nextProbeAt.IsZero() {
+		client.nextProbeAt = time.Now().Add(policy.delay(0))
+		return false
+	}
+
+	return !time.Now().Before(client.nextProbeAt)
+}
+
+// probeForbidden checks with a fresh fetchLimit whether a forbidden client has recovered,
+// e.g. a locked account which has been unlocked, and puts it back in rotation if so.
+func (client *Client) probeForbidden(apiName string, policy BackoffPolicy) {
+	probe := &Client{
+		baseClient: client.baseClient,
+		baseURL:    client.baseURL,
+		callLimit:  client.callLimit,
+		mtx:        &sync.Mutex{},
+	}
+	err := probe.fetchLimit()
+
+	client.mtx.Lock()
+	defer client.mtx.Unlock()
+
+	if !client.forbidden {
+		// cleared in the meantime
+		return
+	}
+
+	if err == nil && !probe.forbidden {
+		client.forbidden = false
+		client.probeAttempts = 0
+		client.nextProbeAt = time.Time{}
+		client.remaining = probe.remaining
+		client.reset = probe.reset
+		client.baseClient.logger.Info("client is not forbidden anymore", "api", apiName)
+		client.capacity.broadcast()
+		return
+	}
+
+	reason := probe.lastError
+	if err != nil {
+		reason = err.Error()
+	}
+	client.lastError = reason
+	client.lastErrorAt = probe.lastErrorAt
+
+	client.probeAttempts++
+	if policy.exhausted(client.probeAttempts) {
+		client.baseClient.logger.Warn("client is still forbidden, giving up", "api", apiName, "probes", client.probeAttempts, "reason", reason)
+		return
+	}
+
+	client.nextProbeAt = time.Now().Add(policy.delay(client.probeAttempts))
+}
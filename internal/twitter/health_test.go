@@ -0,0 +1,93 @@
+package twitter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoffPolicyDelay(t *testing.T) {
+	policy := BackoffPolicy{Min: time.Second, Max: time.Minute}
+
+	for attempt, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		d := policy.delay(attempt)
+		assert.GreaterOrEqual(t, d, max/2)
+		assert.Less(t, d, max)
+	}
+
+	assert.LessOrEqual(t, policy.delay(10), time.Minute)
+	assert.LessOrEqual(t, policy.delay(100), time.Minute)
+	assert.False(t, policy.exhausted(100))
+	assert.True(t, BackoffPolicy{MaxAttempts: 3}.exhausted(3))
+}
+
+func newForbiddenClient(t *testing.T, forbidden *atomic.Bool) *Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if forbidden.Load() {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("X-Rate-Limit-Remaining", "42")
+		w.Header().Set("X-Rate-Limit-Reset", "1700000000")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(server.Close)
+
+	baseClient, err := NewBaseClientFromCookies(context.Background(), "test", nil)
+	assert.NoError(t, err)
+
+	return &Client{
+		baseClient: baseClient,
+		baseURL:    server.URL,
+		callLimit:  500,
+		capacity:   newBroadcaster(),
+		mtx:        &sync.Mutex{},
+	}
+}
+
+func TestClientProbeForbidden(t *testing.T) {
+	forbidden := &atomic.Bool{}
+	forbidden.Store(true)
+	client := newForbiddenClient(t, forbidden)
+	policy := BackoffPolicy{Min: time.Millisecond, Max: time.Millisecond, MaxAttempts: 2}
+
+	assert.NoError(t, client.fetchLimit())
+	assert.True(t, client.forbidden)
+
+	// the first check only schedules a probe
+	assert.False(t, client.probeDue(policy))
+	time.Sleep(2 * time.Millisecond)
+	assert.True(t, client.probeDue(policy))
+
+	client.probeForbidden(apiCallFollowing, policy)
+	assert.True(t, client.forbidden)
+	assert.Equal(t, 1, client.probeAttempts)
+
+	forbidden.Store(false)
+	time.Sleep(2 * time.Millisecond)
+	assert.True(t, client.probeDue(policy))
+	client.probeForbidden(apiCallFollowing, policy)
+	assert.False(t, client.forbidden)
+	assert.Equal(t, 0, client.probeAttempts)
+	assert.Equal(t, int64(42), client.remaining)
+}
+
+func TestClientProbeForbiddenGivesUp(t *testing.T) {
+	forbidden := &atomic.Bool{}
+	forbidden.Store(true)
+	client := newForbiddenClient(t, forbidden)
+	policy := BackoffPolicy{Min: time.Millisecond, Max: time.Millisecond, MaxAttempts: 1}
+
+	assert.NoError(t, client.fetchLimit())
+	client.probeForbidden(apiCallFollowing, policy)
+
+	time.Sleep(2 * time.Millisecond)
+	assert.False(t, client.probeDue(policy))
+	assert.True(t, client.forbidden)
+}
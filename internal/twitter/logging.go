@@ -0,0 +1,40 @@
+package twitter
+
+import (
+	"context"
+	"log/slog"
+)
+
+type requestIDKey struct{}
+
+type logAttrsKey struct{}
+
+// WithRequestID tags ctx with the ID of the incoming request, so that the log entries of the API calls made
+// for it can be correlated.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID returns the request ID ctx is tagged with, if any.
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// withLogAttrs adds attributes, as slog key-value pairs, to the log entries of the API calls made with ctx.
+func withLogAttrs(ctx context.Context, args ...any) context.Context {
+	attrs, _ := ctx.Value(logAttrsKey{}).([]any)
+	return context.WithValue(ctx, logAttrsKey{}, append(attrs[:len(attrs):len(attrs)], args...))
+}
+
+// contextLogger returns logger with the request ID and the attributes ctx is tagged with.
+func contextLogger(ctx context.Context, logger *slog.Logger) *slog.Logger {
+	if requestID := RequestID(ctx); requestID != "" {
+		logger = logger.With("request_id", requestID)
+	}
+	if attrs, _ := ctx.Value(logAttrsKey{}).([]any); len(attrs) > 0 {
+		logger = logger.With(attrs...)
+	}
+
+	return logger
+}
@@ -0,0 +1,37 @@
+package twitter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCrawlerDoRequestLogging(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	crawler, server := newTestCrawler(t, time.Now().Add(time.Hour).Unix(), WithLogger(logger))
+
+	client := crawler.clients[apiCallFavoriters]["test"]
+	client.mtx.Lock()
+	client.remaining = 1
+	client.mtx.Unlock()
+
+	ctx := withLogAttrs(WithRequestID(context.Background(), "req-1"), "cursor", "c-1")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	_, err := crawler.doRequest(apiCallFavoriters, req)
+	assert.NoError(t, err)
+
+	var entry map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "twitter responded", entry["msg"])
+	assert.Equal(t, "req-1", entry["request_id"])
+	assert.Equal(t, "c-1", entry["cursor"])
+	assert.Equal(t, apiCallFavoriters, entry["api"])
+	assert.Equal(t, "test", entry["account"])
+}
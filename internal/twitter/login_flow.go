@@ -0,0 +1,283 @@
+package twitter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	onboardingTaskPath = "/1.1/onboarding/task.json"
+
+	// loginFlowInput starts the login flow, subtask_versions tells which subtasks we know about
+	loginFlowInput = `{"input_flow_data":{"flow_context":{"debug_overrides":{},"start_location":{"location":"unknown"}}},"subtask_versions":{"action_list":2,"alert_dialog":1,"app_download_cta":1,"check_logged_in_account":1,"choice_selection":3,"contacts_live_sync_permission_prompt":0,"cta":7,"email_verification":2,"end_flow":1,"enter_date":1,"enter_email":2,"enter_password":5,"enter_phone":2,"enter_recaptcha":1,"enter_text":5,"enter_username":2,"generic_urt":3,"in_app_notification":1,"interest_picker":3,"js_instrumentation":1,"menu_dialog":1,"notifications_permission_prompt":2,"open_account":2,"open_home_timeline":1,"open_link":1,"phone_verification":4,"privacy_options":1,"security_key":3,"select_avatar":4,"select_banner":2,"settings_list":7,"show_code":1,"sign_up":2,"sign_up_review":4,"tweet_selection_urt":1,"update_users":1,"upload_media":1,"user_recommendations_list":4,"user_recommendations_urt":1,"wait_spinner":3,"web_modal":1}}`
+
+	// maxLoginSteps guards against flows going round in circles
+	maxLoginSteps = 20
+)
+
+var (
+	// ErrUnknownSubtask is returned when the login flow asks for a subtask without a registered handler.
+	ErrUnknownSubtask = errors.New("unknown subtask")
+	// ErrLoginChallenge is returned when the login flow asks for something the credential does not have,
+	// e.g. a 2FA code without totp_secret. LoginSubtaskError.SubtaskID names the challenge.
+	ErrLoginChallenge = errors.New("cannot answer login challenge")
+)
+
+// LoginSubtaskError is returned when the login flow cannot get past a subtask.
+type LoginSubtaskError struct {
+	SubtaskID string
+	Message   string // shown to the user by Twitter, if any
+	Err       error
+}
+
+func (err *LoginSubtaskError) Error() string {
+	if err.Message == "" {
+		return fmt.Sprintf("%s failed: %s", err.SubtaskID, err.Err)
+	}
+	return fmt.Sprintf("%s failed: %s (%s)", err.SubtaskID, err.Err, err.Message)
+}
+
+func (err *LoginSubtaskError) Unwrap() error {
+	return err.Err
+}
+
+type onboardingResponse struct {
+	FlowToken string              `json:"flow_token"`
+	Status    string              `json:"status"`
+	Subtasks  []onboardingSubtask `json:"subtasks"`
+	Errors    []Error             `json:"errors"`
+}
+
+type onboardingText struct {
+	Text string `json:"text"`
+}
+
+type onboardingSubtask struct {
+	SubtaskID string `json:"subtask_id"`
+
+	// only the parts we need to describe the subtask
+	EnterText *struct {
+		PrimaryText   onboardingText `json:"primary_text"`
+		SecondaryText onboardingText `json:"secondary_text"`
+		HintText      string         `json:"hint_text"`
+		KeyboardType  string         `json:"keyboard_type"`
+	} `json:"enter_text"`
+	Cta *struct {
+		PrimaryText   onboardingText `json:"primary_text"`
+		SecondaryText onboardingText `json:"secondary_text"`
+	} `json:"cta"`
+}
+
+// message returns the text Twitter shows for the subtask.
+func (subtask onboardingSubtask) message() string {
+	switch {
+	case subtask.EnterText != nil:
+		return subtask.EnterText.PrimaryText.Text
+	case subtask.Cta != nil && subtask.Cta.SecondaryText.Text != "":
+		return fmt.Sprintf("%s: %s", subtask.Cta.PrimaryText.Text, subtask.Cta.SecondaryText.Text)
+	case subtask.Cta != nil:
+		return subtask.Cta.PrimaryText.Text
+	default:
+		return ""
+	}
+}
+
+// subtaskHandler returns the input that completes a subtask.
+// Errors are wrapped into a LoginSubtaskError.
+type subtaskHandler func(baseClient *BaseClient, subtask onboardingSubtask) (map[string]interface{}, error)
+
+const loginSuccessSubtask = "LoginSuccessSubtask"
+
+// loginSubtaskHandlers answers the subtasks of the login flow, whatever order they come in.
+var loginSubtaskHandlers = map[string]subtaskHandler{
+	"LoginJsInstrumentationSubtask": func(_ *BaseClient, _ onboardingSubtask) (map[string]interface{}, error) {
+		return map[string]interface{}{
+			"js_instrumentation": map[string]interface{}{
+				"response": "{}",
+				"link":     "next_link",
+			},
+		}, nil
+	},
+	"LoginEnterUserIdentifierSSO": func(baseClient *BaseClient, _ onboardingSubtask) (map[string]interface{}, error) {
+		return map[string]interface{}{
+			"settings_list": map[string]interface{}{
+				"setting_responses": []map[string]interface{}{
+					{
+						"key": "user_identifier",
+						"response_data": map[string]interface{}{
+							"text_data": map[string]interface{}{
+								"result": baseClient.Username,
+							},
+						},
+					},
+				},
+				"link": "next_link",
+			},
+		}, nil
+	},
+	"LoginEnterAlternateIdentifierSubtask": func(baseClient *BaseClient, subtask onboardingSubtask) (map[string]interface{}, error) {
+		identifier := baseClient.Email
+		if identifier == "" || (subtask.EnterText != nil && subtask.EnterText.KeyboardType == "phone_pad" && baseClient.Phone != "") {
+			identifier = baseClient.Phone
+		}
+		if identifier == "" {
+			return nil, fmt.Errorf("%w: missing email or phone", ErrLoginChallenge)
+		}
+
+		return enterTextInput(identifier), nil
+	},
+	"LoginEnterPassword": func(baseClient *BaseClient, _ onboardingSubtask) (map[string]interface{}, error) {
+		return map[string]interface{}{
+			"enter_password": map[string]interface{}{
+				"password": baseClient.Password,
+				"link":     "next_link",
+			},
+		}, nil
+	},
+	"LoginTwoFactorAuthChallenge": func(baseClient *BaseClient, _ onboardingSubtask) (map[string]interface{}, error) {
+		if baseClient.TOTPSecret == "" {
+			return nil, fmt.Errorf("%w: missing totp secret", ErrLoginChallenge)
+		}
+
+		code, err := generateTOTP(baseClient.TOTPSecret, time.Now())
+		if err != nil {
+			return nil, err
+		}
+
+		return enterTextInput(code), nil
+	},
+	"LoginAcid": func(baseClient *BaseClient, subtask onboardingSubtask) (map[string]interface{}, error) {
+		// either confirms the email of the account, or asks for a code sent to it which we cannot read
+		if subtask.EnterText == nil || subtask.EnterText.KeyboardType != "email" {
+			return nil, fmt.Errorf("%w: verification code sent by email", ErrLoginChallenge)
+		}
+		if baseClient.Email == "" {
+			return nil, fmt.Errorf("%w: missing email", ErrLoginChallenge)
+		}
+
+		return enterTextInput(baseClient.Email), nil
+	},
+	"AccountDuplicationCheck": func(_ *BaseClient, _ onboardingSubtask) (map[string]interface{}, error) {
+		return map[string]interface{}{
+			"check_logged_in_account": map[string]interface{}{
+				"link": "AccountDuplicationCheck_false",
+			},
+		}, nil
+	},
+	"DenyLoginSubtask": func(_ *BaseClient, _ onboardingSubtask) (map[string]interface{}, error) {
+		return nil, fmt.Errorf("%w: login denied", ErrUnauthorized)
+	},
+}
+
+func enterTextInput(text string) map[string]interface{} {
+	return map[string]interface{}{
+		"enter_text": map[string]interface{}{
+			"text": text,
+			"link": "next_link",
+		},
+	}
+}
+
+// runLoginFlow drives the onboarding login flow, answering whatever subtask Twitter asks for next.
+func (baseClient *BaseClient) runLoginFlow(ctx context.Context) error {
+	resp, err := baseClient.onboardingTask(ctx, baseClient.hosts.API+onboardingTaskPath+"?flow_name=login", []byte(loginFlowInput))
+	if err != nil {
+		return fmt.Errorf("failed to start login flow: %w", err)
+	}
+
+	for step := 0; step < maxLoginSteps; step++ {
+		if len(resp.Subtasks) == 0 {
+			if !baseClient.hasAuthToken() {
+				return fmt.Errorf("login flow ended without a session (status %q)", resp.Status)
+			}
+			return nil
+		}
+
+		subtask := resp.Subtasks[0]
+		if subtask.SubtaskID == loginSuccessSubtask {
+			return nil
+		}
+
+		handler, ok := loginSubtaskHandlers[subtask.SubtaskID]
+		if !ok {
+			return &LoginSubtaskError{SubtaskID: subtask.SubtaskID, Message: subtask.message(), Err: ErrUnknownSubtask}
+		}
+
+		input, err := handler(baseClient, subtask)
+		if err != nil {
+			return &LoginSubtaskError{SubtaskID: subtask.SubtaskID, Message: subtask.message(), Err: err}
+		}
+		input["subtask_id"] = subtask.SubtaskID
+
+		reqBodyBz, _ := json.Marshal(map[string]interface{}{
+			"flow_token":     resp.FlowToken,
+			"subtask_inputs": []map[string]interface{}{input},
+		})
+		resp, err = baseClient.onboardingTask(ctx, baseClient.hosts.API+onboardingTaskPath, reqBodyBz)
+		if err != nil {
+			return &LoginSubtaskError{SubtaskID: subtask.SubtaskID, Err: err}
+		}
+	}
+
+	return fmt.Errorf("login flow did not finish in %d steps", maxLoginSteps)
+}
+
+func (baseClient *BaseClient) onboardingTask(ctx context.Context, url string, reqBodyBz []byte) (*onboardingResponse, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(reqBodyBz))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", defaultBearerToken))
+	cookies := baseClient.httpClient.Jar.Cookies(req.URL)
+	for _, c := range cookies {
+		if c.Name == "gt" {
+			req.Header.Set("X-Guest-Token", c.Value)
+			break
+		}
+	}
+	req = req.WithContext(ctx)
+
+	resp, err := baseClient.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	bodyBz, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var respBody onboardingResponse
+	err = json.Unmarshal(bodyBz, &respBody)
+	if len(respBody.Errors) > 0 {
+		return nil, &APIError{Errors: respBody.Errors}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &respBody, nil
+}
+
+func (baseClient *BaseClient) hasAuthToken() bool {
+	for _, c := range baseClient.httpClient.Jar.Cookies(baseClient.cookieURL()) {
+		if c.Name == "auth_token" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,144 @@
+package twitter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (fn roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
+
+// newLoginFlowClient returns a base client whose onboarding flow asks for subtasks in order,
+// and records the inputs it gets.
+func newLoginFlowClient(t *testing.T, credential Credential, subtaskIDs []string) (*BaseClient, *[]map[string]interface{}) {
+	inputs := make([]map[string]interface{}, 0)
+	step := 0
+
+	baseClient, err := newBaseClient(credential)
+	assert.NoError(t, err)
+
+	jar, _ := cookiejar.New(nil)
+	baseClient.httpClient = &http.Client{Jar: jar, Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		var reqBody struct {
+			SubtaskInputs []map[string]interface{} `json:"subtask_inputs"`
+		}
+		_ = json.NewDecoder(req.Body).Decode(&reqBody)
+		inputs = append(inputs, reqBody.SubtaskInputs...)
+
+		header := http.Header{}
+		subtasks := make([]map[string]interface{}, 0)
+		if step < len(subtaskIDs) {
+			subtasks = append(subtasks, map[string]interface{}{"subtask_id": subtaskIDs[step]})
+		}
+		if step == len(subtaskIDs)-1 {
+			header.Add("Set-Cookie", "auth_token=a; Domain=.twitter.com; Path=/")
+		}
+		step++
+
+		bodyBz, _ := json.Marshal(map[string]interface{}{
+			"flow_token": fmt.Sprintf("token-%d", step),
+			"subtasks":   subtasks,
+		})
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     header,
+			Body:       io.NopCloser(bytes.NewReader(bodyBz)),
+			Request:    req,
+		}, nil
+	})}
+
+	return baseClient, &inputs
+}
+
+func TestLoginFlow(t *testing.T) {
+	credential := Credential{Username: "u", Password: "p", TOTPSecret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"}
+	baseClient, inputs := newLoginFlowClient(t, credential, []string{
+		"LoginJsInstrumentationSubtask",
+		"LoginEnterUserIdentifierSSO",
+		"LoginEnterPassword",
+		"LoginTwoFactorAuthChallenge",
+		"AccountDuplicationCheck",
+		"LoginSuccessSubtask",
+	})
+
+	err := baseClient.runLoginFlow(contextWithTimeout(t))
+	assert.NoError(t, err)
+
+	subtaskIDs := make([]interface{}, 0)
+	for _, input := range *inputs {
+		subtaskIDs = append(subtaskIDs, input["subtask_id"])
+	}
+	assert.Equal(t, []interface{}{
+		"LoginJsInstrumentationSubtask",
+		"LoginEnterUserIdentifierSSO",
+		"LoginEnterPassword",
+		"LoginTwoFactorAuthChallenge",
+		"AccountDuplicationCheck",
+	}, subtaskIDs)
+	assert.Equal(t, "p", (*inputs)[2]["enter_password"].(map[string]interface{})["password"])
+	assert.Len(t, (*inputs)[3]["enter_text"].(map[string]interface{})["text"], totpDigits)
+}
+
+func TestLoginFlowUnknownSubtask(t *testing.T) {
+	baseClient, _ := newLoginFlowClient(t, Credential{Username: "u", Password: "p"}, []string{
+		"LoginEnterUserIdentifierSSO",
+		"LoginSomethingNew",
+	})
+
+	err := baseClient.runLoginFlow(contextWithTimeout(t))
+	assert.ErrorIs(t, err, ErrUnknownSubtask)
+
+	var subtaskErr *LoginSubtaskError
+	assert.ErrorAs(t, err, &subtaskErr)
+	assert.Equal(t, "LoginSomethingNew", subtaskErr.SubtaskID)
+}
+
+func TestLoginFlowMissingTOTPSecret(t *testing.T) {
+	baseClient, _ := newLoginFlowClient(t, Credential{Username: "u", Password: "p"}, []string{
+		"LoginEnterPassword",
+		"LoginTwoFactorAuthChallenge",
+	})
+
+	err := baseClient.runLoginFlow(contextWithTimeout(t))
+	assert.ErrorIs(t, err, ErrLoginChallenge)
+}
+
+func TestLoginFlowAlternateIdentifier(t *testing.T) {
+	subtaskIDs := []string{
+		"LoginEnterUserIdentifierSSO",
+		"LoginEnterAlternateIdentifierSubtask",
+		"LoginEnterPassword",
+		"LoginSuccessSubtask",
+	}
+
+	baseClient, inputs := newLoginFlowClient(t, Credential{Username: "u", Password: "p", Email: "u@example.com"}, subtaskIDs)
+	err := baseClient.runLoginFlow(contextWithTimeout(t))
+	assert.NoError(t, err)
+	assert.Equal(t, "u@example.com", (*inputs)[1]["enter_text"].(map[string]interface{})["text"])
+
+	baseClient, _ = newLoginFlowClient(t, Credential{Username: "u", Password: "p"}, subtaskIDs)
+	err = baseClient.runLoginFlow(contextWithTimeout(t))
+	assert.ErrorIs(t, err, ErrLoginChallenge)
+
+	var subtaskErr *LoginSubtaskError
+	assert.ErrorAs(t, err, &subtaskErr)
+	assert.Equal(t, "LoginEnterAlternateIdentifierSubtask", subtaskErr.SubtaskID)
+}
+
+func contextWithTimeout(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
@@ -0,0 +1,158 @@
+package twitter
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsNamespace = "teatweet"
+
+// metrics records the Prometheus metrics of a crawler, see WithMetrics.
+// A nil *metrics records nothing.
+type metrics struct {
+	requests    *prometheus.CounterVec
+	latency     *prometheus.HistogramVec
+	rateLimited *prometheus.CounterVec
+	reconnects  *prometheus.CounterVec
+	items       *prometheus.HistogramVec
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "twitter_requests_total",
+			Help:      "Requests sent to Twitter by API call, account and status code (\"error\" if none was received).",
+		}, []string{"api", "account", "code"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "twitter_request_duration_seconds",
+			Help:      "Latency of requests sent to Twitter by API call.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"api"}),
+		rateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "rate_limit_errors_total",
+			Help:      "API calls which failed because every account was rate limited.",
+		}, []string{"api"}),
+		reconnects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "reconnect_attempts_total",
+			Help:      "Logins of accounts whose session was rejected, by result.",
+		}, []string{"account", "result"}),
+		items: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "crawl_items",
+			Help:      "Items returned per crawled page, by method.",
+			Buckets:   []float64{0, 1, 5, 10, 20, 50, 100},
+		}, []string{"method"}),
+	}
+}
+
+func (m *metrics) observeRequest(call string, account string, statusCode int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	code := "error"
+	if statusCode > 0 {
+		code = strconv.Itoa(statusCode)
+	}
+	m.requests.WithLabelValues(call, account, code).Inc()
+	m.latency.WithLabelValues(call).Observe(duration.Seconds())
+}
+
+func (m *metrics) observeRateLimited(call string) {
+	if m == nil {
+		return
+	}
+
+	m.rateLimited.WithLabelValues(call).Inc()
+}
+
+func (m *metrics) observeReconnect(account string, err error) {
+	if m == nil {
+		return
+	}
+
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	m.reconnects.WithLabelValues(account, result).Inc()
+}
+
+func (m *metrics) observeItems(method string, count int) {
+	if m == nil {
+		return
+	}
+
+	m.items.WithLabelValues(method).Observe(float64(count))
+}
+
+// poolCollector reports the state of the account pools at scrape time.
+type poolCollector struct {
+	crawler *Crawler
+
+	remaining  *prometheus.Desc
+	forbidden  *prometheus.Desc
+	connected  *prometheus.Desc
+	queueDepth *prometheus.Desc
+}
+
+func newPoolCollector(crawler *Crawler) *poolCollector {
+	return &poolCollector{
+		crawler: crawler,
+		remaining: prometheus.NewDesc(
+			prometheus.BuildFQName(metricsNamespace, "", "account_remaining_requests"),
+			"Requests left in the current rate limit window of an account, by API call.",
+			[]string{"api", "account"}, nil,
+		),
+		forbidden: prometheus.NewDesc(
+			prometheus.BuildFQName(metricsNamespace, "", "account_forbidden"),
+			"Whether an account is forbidden by Twitter, by API call.",
+			[]string{"api", "account"}, nil,
+		),
+		connected: prometheus.NewDesc(
+			prometheus.BuildFQName(metricsNamespace, "", "account_connected"),
+			"Whether the session of an account is connected.",
+			[]string{"api", "account"}, nil,
+		),
+		queueDepth: prometheus.NewDesc(
+			prometheus.BuildFQName(metricsNamespace, "", "queue_depth"),
+			"Requests waiting for an account, by API call and priority.",
+			[]string{"api", "priority"}, nil,
+		),
+	}
+}
+
+func (collector *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- collector.remaining
+	ch <- collector.forbidden
+	ch <- collector.connected
+	ch <- collector.queueDepth
+}
+
+func (collector *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	for apiName, statuses := range collector.crawler.Accounts() {
+		for _, status := range statuses {
+			ch <- prometheus.MustNewConstMetric(collector.remaining, prometheus.GaugeValue, float64(status.Remaining), apiName, status.Username)
+			ch <- prometheus.MustNewConstMetric(collector.forbidden, prometheus.GaugeValue, boolToFloat(status.Forbidden), apiName, status.Username)
+			ch <- prometheus.MustNewConstMetric(collector.connected, prometheus.GaugeValue, boolToFloat(status.State == AccountConnected), apiName, status.Username)
+		}
+	}
+
+	for apiName, depth := range collector.crawler.QueueDepths() {
+		ch <- prometheus.MustNewConstMetric(collector.queueDepth, prometheus.GaugeValue, float64(depth.Interactive), apiName, PriorityInteractive.String())
+		ch <- prometheus.MustNewConstMetric(collector.queueDepth, prometheus.GaugeValue, float64(depth.Batch), apiName, PriorityBatch.String())
+	}
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
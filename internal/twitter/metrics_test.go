@@ -0,0 +1,34 @@
+package twitter
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCrawlerMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	crawler, server := newTestCrawler(t, time.Now().Add(time.Hour).Unix(), WithMetrics(registry))
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	_, err := crawler.doRequest(apiCallFavoriters, req)
+	assert.Error(t, err)
+	assert.Equal(t, float64(1), testutil.ToFloat64(crawler.metrics.rateLimited.WithLabelValues(apiCallFavoriters)))
+
+	client := crawler.clients[apiCallFavoriters]["test"]
+	client.mtx.Lock()
+	client.remaining = 1
+	client.mtx.Unlock()
+
+	req, _ = http.NewRequest(http.MethodGet, server.URL, nil)
+	_, err = crawler.doRequest(apiCallFavoriters, req)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(1), testutil.ToFloat64(crawler.metrics.requests.WithLabelValues(apiCallFavoriters, "test", "200")))
+
+	// remaining, forbidden and connected of the single account, and the queue depths of every API call
+	assert.Equal(t, 3+2*len(apis), testutil.CollectAndCount(newPoolCollector(crawler)))
+}
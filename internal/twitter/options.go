@@ -0,0 +1,79 @@
+package twitter
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Option configures a Crawler.
+type Option func(crawler *Crawler)
+
+// WithWaitForCapacity makes API calls wait until a client has capacity again instead of failing with a
+// RateLimitError right away. Waiting is bounded by the request context, after which the RateLimitError is returned.
+func WithWaitForCapacity() Option {
+	return func(crawler *Crawler) {
+		crawler.waitForCapacity = true
+	}
+}
+
+// WithSessionStore makes the crawler restore sessions from store on start and save them after every login.
+func WithSessionStore(store SessionStore) Option {
+	return func(crawler *Crawler) {
+		crawler.sessionStore = store
+	}
+}
+
+// WithForbiddenProbe sets how forbidden clients are re-probed to put them back in rotation once they recover.
+// By default, they are re-probed for about a day.
+func WithForbiddenProbe(policy BackoffPolicy) Option {
+	return func(crawler *Crawler) {
+		crawler.probePolicy = policy
+	}
+}
+
+// WithReconnectPolicy sets how an account whose session was rejected is logged in again.
+// By default, it is retried for about half an hour.
+func WithReconnectPolicy(policy BackoffPolicy) Option {
+	return func(crawler *Crawler) {
+		crawler.reconnectPolicy = policy
+	}
+}
+
+// WithMetrics registers Prometheus metrics of the crawler and its account pools with registerer.
+func WithMetrics(registerer prometheus.Registerer) Option {
+	return func(crawler *Crawler) {
+		crawler.metrics = newMetrics()
+		registerer.MustRegister(
+			crawler.metrics.requests,
+			crawler.metrics.latency,
+			crawler.metrics.rateLimited,
+			crawler.metrics.reconnects,
+			crawler.metrics.items,
+			newPoolCollector(crawler),
+		)
+	}
+}
+
+// WithHosts makes the crawler talk to other hosts than Twitter's, e.g. a fake server in tests.
+func WithHosts(hosts Hosts) Option {
+	return func(crawler *Crawler) {
+		crawler.hosts = hosts
+	}
+}
+
+// WithTransport sets the transport of the HTTP clients of the accounts, http.DefaultTransport by default.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(crawler *Crawler) {
+		crawler.transport = transport
+	}
+}
+
+// WithLogger sets the structured logger of the crawler and its accounts, slog.Default() by default.
+// Entries are tagged with the account, the API call and, if the context is tagged with WithRequestID, the request ID.
+func WithLogger(logger *slog.Logger) Option {
+	return func(crawler *Crawler) {
+		crawler.logger = logger
+	}
+}
@@ -0,0 +1,191 @@
+package twitter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// defaultReconnectPolicy retries a login for about half an hour before giving up.
+var defaultReconnectPolicy = BackoffPolicy{
+	Min:         5 * time.Second,
+	Max:         5 * time.Minute,
+	MaxAttempts: 10,
+}
+
+// reconnector logs an account in again once Twitter rejects its session.
+// There is one per account, shared by the clients of every API call, so that a rejected session
+// triggers a single reconnect loop no matter how many requests saw it.
+type reconnector struct {
+	baseClient *BaseClient
+	clients    []*Client // refreshed after every login
+	policy     BackoffPolicy
+	metrics    *metrics
+
+	ctx    context.Context // done when the account is removed or the crawler is closed
+	cancel context.CancelFunc
+
+	wg            *sync.WaitGroup // done when the loop returns
+	mtx           *sync.Mutex
+	running       bool
+	attempts      int
+	lastError     string
+	nextAttemptAt time.Time
+}
+
+// ReconnectStatus is a snapshot of an account's reconnect loop.
+type ReconnectStatus struct {
+	Running       bool      `json:"running"`
+	Attempts      int       `json:"attempts"`
+	LastError     string    `json:"last_error,omitempty"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+}
+
+func newReconnector(ctx context.Context, baseClient *BaseClient, policy BackoffPolicy) *reconnector {
+	ctx, cancel := context.WithCancel(ctx)
+	return &reconnector{
+		baseClient: baseClient,
+		policy:     policy,
+		ctx:        ctx,
+		cancel:     cancel,
+		wg:         &sync.WaitGroup{},
+		mtx:        &sync.Mutex{},
+	}
+}
+
+// trigger starts the reconnect loop unless it is already running.
+func (r *reconnector) trigger() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.running || r.ctx.Err() != nil {
+		return
+	}
+
+	if !r.baseClient.CanReconnect() {
+		r.lastError = "cannot reconnect: missing credential"
+		r.baseClient.logger.Warn("session is rejected but the client cannot reconnect")
+		return
+	}
+
+	r.running = true
+	r.attempts = 0
+	r.wg.Add(1)
+	go r.run()
+}
+
+func (r *reconnector) run() {
+	defer r.wg.Done()
+	defer func() {
+		r.mtx.Lock()
+		r.running = false
+		r.nextAttemptAt = time.Time{}
+		r.mtx.Unlock()
+	}()
+
+	for attempt := 0; ; attempt++ {
+		if r.baseClient.Connected() {
+			// logged in by someone else, e.g. a forced re-login
+			return
+		}
+
+		r.baseClient.logger.Info("reconnecting", "attempt", attempt+1)
+		err := r.login(r.ctx)
+		if err == nil {
+			r.baseClient.logger.Info("reconnected")
+			return
+		}
+
+		if r.ctx.Err() != nil {
+			return
+		}
+
+		if r.policy.exhausted(attempt+1) || errors.Is(err, ErrLoginChallenge) {
+			r.baseClient.logger.Warn("cannot reconnect, giving up", "attempts", attempt+1, "error", err)
+			return
+		}
+
+		delay := r.policy.delay(attempt)
+		r.mtx.Lock()
+		r.nextAttemptAt = time.Now().Add(delay)
+		r.mtx.Unlock()
+		r.baseClient.logger.Info("cannot reconnect, retrying later", "error", err, "delay", delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-r.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// relogin logs the account in right away, e.g. when forced by an admin.
+// The client is disconnected once the login starts, so if it fails the reconnect loop takes over.
+func (r *reconnector) relogin(ctx context.Context) error {
+	err := r.login(ctx)
+	if err != nil && !errors.Is(err, ErrLoginChallenge) {
+		r.trigger()
+	}
+
+	return err
+}
+
+// login logs the account in and refreshes the rate limits of its clients.
+func (r *reconnector) login(ctx context.Context) error {
+	err := r.baseClient.Login(ctx)
+	r.metrics.observeReconnect(r.baseClient.Username, err)
+
+	r.mtx.Lock()
+	if err != nil {
+		r.attempts++
+		r.lastError = err.Error()
+	} else {
+		r.attempts = 0
+		r.lastError = ""
+	}
+	r.mtx.Unlock()
+
+	if err != nil {
+		for _, client := range r.clients {
+			client.mtx.Lock()
+			client.recordError(err)
+			client.mtx.Unlock()
+		}
+		return err
+	}
+
+	for _, client := range r.clients {
+		err := client.fetchLimit()
+		if err != nil {
+			r.baseClient.logger.Warn("cannot fetch limit after login", "error", err)
+		}
+		client.capacity.broadcast()
+	}
+
+	return nil
+}
+
+// stop cancels the reconnect loop for good.
+func (r *reconnector) stop() {
+	r.cancel()
+}
+
+// wait waits for a stopped reconnect loop to return.
+func (r *reconnector) wait() {
+	r.wg.Wait()
+}
+
+func (r *reconnector) status() ReconnectStatus {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return ReconnectStatus{
+		Running:       r.running,
+		Attempts:      r.attempts,
+		LastError:     r.lastError,
+		NextAttemptAt: r.nextAttemptAt,
+	}
+}
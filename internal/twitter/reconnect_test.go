@@ -0,0 +1,79 @@
+package twitter
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newUnreachableBaseClient returns a base client whose logins always fail, counting the attempts.
+func newUnreachableBaseClient(t *testing.T) (*BaseClient, *atomic.Int64) {
+	logins := &atomic.Int64{}
+
+	baseClient, err := newBaseClient(Credential{Username: "test", Password: "p"})
+	assert.NoError(t, err)
+
+	jar, _ := cookiejar.New(nil)
+	baseClient.httpClient = &http.Client{Jar: jar, Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path == "/" {
+			logins.Add(1)
+		}
+		return &http.Response{
+			StatusCode: http.StatusServiceUnavailable,
+			Header:     http.Header{},
+			Body:       io.NopCloser(strings.NewReader("")),
+		}, nil
+	})}
+
+	return baseClient, logins
+}
+
+func waitForReconnector(t *testing.T, r *reconnector, done func(status ReconnectStatus) bool) ReconnectStatus {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if status := r.status(); done(status) {
+			return status
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	t.Fatal("reconnector did not reach the expected state")
+	return ReconnectStatus{}
+}
+
+func TestReconnectorGivesUp(t *testing.T) {
+	baseClient, logins := newUnreachableBaseClient(t)
+	r := newReconnector(context.Background(), baseClient, BackoffPolicy{Min: time.Millisecond, Max: time.Millisecond, MaxAttempts: 3})
+
+	r.trigger()
+	// a running loop is not started twice
+	r.trigger()
+
+	status := waitForReconnector(t, r, func(status ReconnectStatus) bool { return !status.Running })
+	assert.Equal(t, 3, status.Attempts)
+	assert.NotEmpty(t, status.LastError)
+	assert.Equal(t, int64(3), logins.Load())
+}
+
+func TestReconnectorStop(t *testing.T) {
+	baseClient, logins := newUnreachableBaseClient(t)
+	r := newReconnector(context.Background(), baseClient, BackoffPolicy{Min: time.Hour, Max: time.Hour})
+
+	r.trigger()
+	waitForReconnector(t, r, func(status ReconnectStatus) bool { return status.Attempts == 1 })
+
+	r.stop()
+	waitForReconnector(t, r, func(status ReconnectStatus) bool { return !status.Running })
+	assert.Equal(t, int64(1), logins.Load())
+
+	// a stopped reconnector never starts again
+	r.trigger()
+	assert.False(t, r.status().Running)
+}
@@ -0,0 +1,280 @@
+package twitter
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Priority is the scheduling class of an API call, see WithPriority.
+type Priority int
+
+const (
+	// PriorityInteractive requests are always dispatched before batch ones.
+	PriorityInteractive Priority = iota
+	// PriorityBatch requests are dispatched when no interactive request is waiting,
+	// and they cannot take the last batchReservePercent of a client's quota.
+	PriorityBatch
+
+	numPriorities = 2
+
+	batchReservePercent = 10
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityInteractive:
+		return "interactive"
+	case PriorityBatch:
+		return "batch"
+	default:
+		return "unknown"
+	}
+}
+
+type (
+	priorityKey struct{}
+	callerKey   struct{}
+)
+
+// WithPriority returns a context whose API calls are scheduled with the given priority.
+// Calls are interactive by default.
+func WithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// WithCaller returns a context whose API calls are queued on behalf of caller.
+// Waiting calls of the same priority are served round-robin across callers.
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+func priorityFromContext(ctx context.Context) Priority {
+	priority, ok := ctx.Value(priorityKey{}).(Priority)
+	if !ok || priority < 0 || priority >= numPriorities {
+		return PriorityInteractive
+	}
+	return priority
+}
+
+func callerFromContext(ctx context.Context) string {
+	caller, _ := ctx.Value(callerKey{}).(string)
+	return caller
+}
+
+// QueueDepth is the number of API calls waiting for a client.
+type QueueDepth struct {
+	Interactive int `json:"interactive"`
+	Batch       int `json:"batch"`
+	Callers     int `json:"callers"`
+}
+
+type ticket struct {
+	caller   string
+	priority Priority
+	ready    chan *Client // receives the acquired client, buffered
+}
+
+type callerQueue struct {
+	caller  string
+	tickets []*ticket
+}
+
+// scheduler hands out the clients of an API call to waiting requests.
+// Requests are served by priority, then round-robin across callers, then in FIFO order.
+// Requests only wait with WithWaitForCapacity, otherwise they fail as soon as no client is available,
+// and the ordering only applies to the few requests arriving at the same time.
+type scheduler struct {
+	clients  func() []*Client
+	capacity *broadcaster // notified whenever a client may have capacity again
+
+	mtx     *sync.Mutex
+	classes [numPriorities][]*callerQueue // callers with waiting tickets, in round-robin order
+	depths  [numPriorities]int
+}
+
+func newScheduler(clients func() []*Client) *scheduler {
+	return &scheduler{
+		clients:  clients,
+		capacity: newBroadcaster(),
+		mtx:      &sync.Mutex{},
+	}
+}
+
+// acquire waits for a client for the caller in ctx. It returns ErrNoClient if no client is connected
+// and a RateLimitError if every client is rate limited, unless wait is set, in which case it waits until
+// a client has capacity or ctx is done.
+func (s *scheduler) acquire(ctx context.Context, wait bool) (*Client, error) {
+	t := &ticket{
+		caller:   callerFromContext(ctx),
+		priority: priorityFromContext(ctx),
+		ready:    make(chan *Client, 1),
+	}
+
+	s.mtx.Lock()
+	s.enqueue(t)
+	s.mtx.Unlock()
+
+	for {
+		// must be taken before dispatching, a client may free up in between
+		capacityCh := s.capacity.wait()
+
+		s.mtx.Lock()
+		retryAfter := s.dispatch()
+		s.mtx.Unlock()
+
+		select {
+		case client := <-t.ready:
+			return client, nil
+		default:
+		}
+
+		if retryAfter == math.MaxInt64 {
+			return s.cancel(ctx, t, ErrNoClient)
+		}
+
+		rateLimitErr := &RateLimitError{Reset: retryAfter}
+		if !wait {
+			return s.cancel(ctx, t, rateLimitErr)
+		}
+
+		// rate limit resets are in seconds, give it a little slack
+		timer := time.NewTimer(time.Until(time.Unix(retryAfter, 0)) + time.Second)
+		select {
+		case client := <-t.ready:
+			timer.Stop()
+			return client, nil
+		case <-ctx.Done():
+			timer.Stop()
+			return s.cancel(ctx, t, rateLimitErr)
+		case <-capacityCh:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// cancel removes t from the queue and returns err, unless t has been served meanwhile.
+// A client served to a request whose ctx is done is released, and the error of ctx returned.
+func (s *scheduler) cancel(ctx context.Context, t *ticket, err error) (*Client, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.remove(t) {
+		return nil, err
+	}
+
+	client := <-t.ready
+	if ctx.Err() != nil {
+		client.release()
+		return nil, ctx.Err()
+	}
+
+	return client, nil
+}
+
+// QueueDepth returns the number of waiting requests.
+func (s *scheduler) QueueDepth() QueueDepth {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return QueueDepth{
+		Interactive: s.depths[PriorityInteractive],
+		Batch:       s.depths[PriorityBatch],
+		Callers:     len(s.classes[PriorityInteractive]) + len(s.classes[PriorityBatch]),
+	}
+}
+
+func (s *scheduler) enqueue(t *ticket) {
+	s.depths[t.priority]++
+	for _, cq := range s.classes[t.priority] {
+		if cq.caller == t.caller {
+			cq.tickets = append(cq.tickets, t)
+			return
+		}
+	}
+
+	s.classes[t.priority] = append(s.classes[t.priority], &callerQueue{caller: t.caller, tickets: []*ticket{t}})
+}
+
+func (s *scheduler) remove(t *ticket) bool {
+	queues := s.classes[t.priority]
+	for i, cq := range queues {
+		if cq.caller != t.caller {
+			continue
+		}
+
+		for j, tt := range cq.tickets {
+			if tt != t {
+				continue
+			}
+
+			cq.tickets = append(cq.tickets[:j], cq.tickets[j+1:]...)
+			if len(cq.tickets) == 0 {
+				s.classes[t.priority] = append(queues[:i], queues[i+1:]...)
+			}
+			s.depths[t.priority]--
+			return true
+		}
+	}
+
+	return false
+}
+
+// dispatch hands clients to waiting tickets in order until no client is available.
+// It returns the earliest time a client may become available again, math.MaxInt64 if none will.
+func (s *scheduler) dispatch() int64 {
+	retryAfter := int64(-1)
+	for priority := range s.classes {
+		for len(s.classes[priority]) > 0 {
+			var client *Client
+			client, retryAfter = s.acquireClient(Priority(priority))
+			if client == nil {
+				return retryAfter
+			}
+
+			cq := s.classes[priority][0]
+			t := cq.tickets[0]
+			cq.tickets = cq.tickets[1:]
+			s.classes[priority] = s.classes[priority][1:]
+			if len(cq.tickets) > 0 {
+				// next round for this caller
+				s.classes[priority] = append(s.classes[priority], cq)
+			}
+			s.depths[priority]--
+
+			t.ready <- client
+		}
+	}
+
+	return retryAfter
+}
+
+// acquireClient picks a random available client.
+func (s *scheduler) acquireClient(priority Priority) (*Client, int64) {
+	clients := s.clients()
+	retryAfterInt64 := int64(math.MaxInt64)
+	for _, j := range rand.Perm(len(clients)) {
+		client := clients[j]
+
+		reserve := int64(0)
+		if priority == PriorityBatch {
+			reserve = client.callLimit * batchReservePercent / 100
+		}
+
+		// check if limited
+		ok, retryAfter := client.isAvailable(reserve)
+		if !ok {
+			if retryAfter >= 0 && retryAfter < retryAfterInt64 {
+				retryAfterInt64 = retryAfter
+			}
+			continue
+		}
+
+		return client, -1
+	}
+
+	return nil, retryAfterInt64
+}
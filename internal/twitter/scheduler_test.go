@@ -0,0 +1,75 @@
+package twitter
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedulerDispatchOrder(t *testing.T) {
+	baseClient, err := NewBaseClientFromCookies(context.Background(), "test", nil)
+	assert.NoError(t, err)
+
+	client := &Client{
+		baseClient: baseClient,
+		callLimit:  0,
+		mtx:        &sync.Mutex{},
+	}
+	s := newScheduler(func() []*Client { return []*Client{client} })
+
+	newTicket := func(caller string, priority Priority) *ticket {
+		tt := &ticket{caller: caller, priority: priority, ready: make(chan *Client, 1)}
+		s.enqueue(tt)
+		return tt
+	}
+	a1 := newTicket("a", PriorityBatch)
+	a2 := newTicket("a", PriorityBatch)
+	a3 := newTicket("a", PriorityBatch)
+	b1 := newTicket("b", PriorityBatch)
+	c1 := newTicket("c", PriorityInteractive)
+
+	assert.Equal(t, QueueDepth{Interactive: 1, Batch: 4, Callers: 3}, s.QueueDepth())
+
+	expected := []*ticket{c1, a1, b1, a2, a3}
+	for i, tt := range expected {
+		// one more slot per round
+		client.callLimit = int64(i + 1)
+
+		s.dispatch()
+		assert.Len(t, tt.ready, 1, "ticket %d", i)
+		for _, other := range expected[i+1:] {
+			assert.Len(t, other.ready, 0, "ticket %d", i)
+		}
+		<-tt.ready
+	}
+
+	assert.Equal(t, QueueDepth{}, s.QueueDepth())
+}
+
+func TestSchedulerCancelServed(t *testing.T) {
+	baseClient, err := NewBaseClientFromCookies(context.Background(), "test", nil)
+	assert.NoError(t, err)
+
+	client := &Client{
+		baseClient: baseClient,
+		callLimit:  1,
+		mtx:        &sync.Mutex{},
+	}
+	s := newScheduler(func() []*Client { return []*Client{client} })
+	client.capacity = s.capacity
+
+	// served right before its context is done
+	tt := &ticket{ready: make(chan *Client, 1)}
+	s.enqueue(tt)
+	s.dispatch()
+	assert.Equal(t, int64(1), client.pending)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	served, err := s.cancel(ctx, tt, ErrNoClient)
+	assert.Nil(t, served)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, int64(0), client.pending)
+}
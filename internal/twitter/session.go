@@ -0,0 +1,181 @@
+package twitter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrSessionNotFound is returned by SessionStore.Load when no session has been saved for an account.
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session is the cookie jar of a logged-in account, e.g. auth_token, ct0 and twid.
+type Session struct {
+	Username string            `json:"username"`
+	Cookies  map[string]string `json:"cookies"`
+	SavedAt  time.Time         `json:"saved_at"`
+}
+
+// SessionStore persists sessions so that restarts do not need to log in every account again.
+type SessionStore interface {
+	Load(username string) (*Session, error)
+	Save(session *Session) error
+}
+
+// FileSessionStore stores each session as a JSON file in a directory.
+type FileSessionStore struct {
+	dir string
+}
+
+var _ SessionStore = (*FileSessionStore)(nil)
+
+func NewFileSessionStore(dir string) (*FileSessionStore, error) {
+	err := os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileSessionStore{dir: dir}, nil
+}
+
+func (store *FileSessionStore) Load(username string) (*Session, error) {
+	bz, err := os.ReadFile(store.path(username))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var session Session
+	err = json.Unmarshal(bz, &session)
+	if err != nil {
+		return nil, fmt.Errorf("corrupted session file: %w", err)
+	}
+
+	return &session, nil
+}
+
+func (store *FileSessionStore) Save(session *Session) error {
+	bz, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// write then rename, so that a crash never leaves a truncated file behind
+	f, err := os.CreateTemp(store.dir, ".session-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	_, err = f.Write(bz)
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), store.path(session.Username))
+}
+
+func (store *FileSessionStore) path(username string) string {
+	return filepath.Join(store.dir, url.PathEscape(username)+".json")
+}
+
+// saveSession persists the cookie jar of the base client, if it has a session store.
+func (baseClient *BaseClient) saveSession() error {
+	if baseClient.sessionStore == nil {
+		return nil
+	}
+
+	cookies := make(map[string]string)
+	for _, c := range baseClient.httpClient.Jar.Cookies(baseClient.cookieURL()) {
+		cookies[c.Name] = c.Value
+	}
+
+	return baseClient.sessionStore.Save(&Session{
+		Username: baseClient.Username,
+		Cookies:  cookies,
+		SavedAt:  time.Now(),
+	})
+}
+
+// persistSession saves the session of a connected base client, e.g. on shutdown, since cookies are refreshed over time.
+func (baseClient *BaseClient) persistSession() error {
+	baseClient.mtx.RLock()
+	defer baseClient.mtx.RUnlock()
+
+	if !baseClient.Connected() {
+		return nil
+	}
+
+	return baseClient.saveSession()
+}
+
+// restoreSession loads the saved cookie jar of the base client and marks it as connected.
+// It returns false if there is no saved session.
+func (baseClient *BaseClient) restoreSession() (bool, error) {
+	if baseClient.sessionStore == nil {
+		return false, nil
+	}
+
+	session, err := baseClient.sessionStore.Load(baseClient.Username)
+	if errors.Is(err, ErrSessionNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	cookies := make([]*http.Cookie, 0, len(session.Cookies))
+	for name, value := range session.Cookies {
+		cookies = append(cookies, &http.Cookie{
+			Name:   name,
+			Value:  value,
+			Path:   "/",
+			Secure: true,
+		})
+	}
+
+	baseClient.mtx.Lock()
+	defer baseClient.mtx.Unlock()
+
+	baseClient.setCookies(cookies)
+
+	baseClient.rMtx.Lock()
+	baseClient.connected = true
+	baseClient.lastSyncedAt = time.Now()
+	baseClient.rMtx.Unlock()
+
+	return true, nil
+}
+
+// verifySession probes an API with the current session.
+func (baseClient *BaseClient) verifySession() error {
+	probe := &Client{
+		baseClient: baseClient,
+		baseURL:    baseClient.hosts.Web + apis[apiCallFollowing].Path,
+		mtx:        &sync.Mutex{},
+	}
+
+	err := probe.fetchLimit()
+	if err != nil {
+		return err
+	}
+	if probe.forbidden {
+		return ErrUnauthorized
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package twitter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSessionStore(t *testing.T) {
+	store, err := NewFileSessionStore(t.TempDir())
+	assert.NoError(t, err)
+
+	_, err = store.Load("someone@example.com")
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+
+	baseClient, err := newBaseClient(Credential{Username: "someone@example.com", Password: "p"})
+	assert.NoError(t, err)
+	baseClient.sessionStore = store
+
+	restored, err := baseClient.restoreSession()
+	assert.NoError(t, err)
+	assert.False(t, restored)
+
+	err = store.Save(&Session{
+		Username: "someone@example.com",
+		Cookies:  map[string]string{"auth_token": "a", "ct0": "c", "twid": "u%3D1"},
+		SavedAt:  time.Now(),
+	})
+	assert.NoError(t, err)
+
+	restored, err = baseClient.restoreSession()
+	assert.NoError(t, err)
+	assert.True(t, restored)
+	assert.True(t, baseClient.Connected())
+	// a restored session is not a login
+	assert.True(t, baseClient.LastLoginAt().IsZero())
+
+	err = baseClient.saveSession()
+	assert.NoError(t, err)
+
+	session, err := store.Load("someone@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"auth_token": "a", "ct0": "c", "twid": "u%3D1"}, session.Cookies)
+}
@@ -0,0 +1,440 @@
+// Package twittertest provides an offline Twitter for tests of the crawler and of its users.
+package twittertest
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	fakeGuestToken = "1700000000000000000"
+
+	onboardingTaskPath  = "/1.1/onboarding/task.json"
+	loginSuccessSubtask = "LoginSuccessSubtask"
+)
+
+//go:embed testdata/*.json
+var fixtures embed.FS
+
+var (
+	regexFakeGraphQLPath = regexp.MustCompile(`^/i/api/graphql/[^/]+/(\w+)$`)
+
+	// fakeLookupKeys are the variables the fixtures of lookup operations are keyed by, joined by spaces,
+	// others are keyed by cursor
+	fakeLookupKeys = map[string][]string{
+		"UserByScreenName": {"screen_name"},
+		"UserByRestID":     {"userId"},
+		"TweetDetail":      {"focalTweetId", "cursor"},
+	}
+	// fakeBulkLookupKeys are the variables listing the keys of bulk lookup operations, whose fixtures map each key to an item
+	fakeBulkLookupKeys = map[string]string{
+		"UsersByRestIds": "userIds",
+	}
+)
+
+// Server is an offline Twitter serving the login flow and the GraphQL APIs of the crawler,
+// the latter from the fixtures in testdata, one file per operation mapping cursors, or lookup keys, to responses.
+// It serves both the web and the API hosts.
+type Server struct {
+	*httptest.Server
+
+	mtx      *sync.Mutex
+	accounts map[string]fakeAccount
+	flows    map[string]*fakeFlow
+	sessions map[string]string // auth_token => username
+	pages    map[string]map[string]json.RawMessage
+	limits   map[string]*fakeRateLimit
+	failures map[string][]int
+	logins   map[string]int
+	requests map[string]int
+}
+
+type fakeAccount struct {
+	ID       string
+	Password string
+}
+
+type fakeFlow struct {
+	username string
+	next     string
+}
+
+type fakeRateLimit struct {
+	remaining int64
+	reset     int64
+}
+
+// fakeLoginSubtasks is the happy path of the login flow, each subtask is asked once the previous one is answered.
+var fakeLoginSubtasks = []string{
+	"LoginJsInstrumentationSubtask",
+	"LoginEnterUserIdentifierSSO",
+	"LoginEnterPassword",
+	"AccountDuplicationCheck",
+	loginSuccessSubtask,
+}
+
+// NewServer starts a Server, it is closed when tb ends.
+func NewServer(tb testing.TB) *Server {
+	fake := &Server{
+		mtx:      &sync.Mutex{},
+		accounts: make(map[string]fakeAccount),
+		flows:    make(map[string]*fakeFlow),
+		sessions: make(map[string]string),
+		pages:    make(map[string]map[string]json.RawMessage),
+		limits:   make(map[string]*fakeRateLimit),
+		failures: make(map[string][]int),
+		logins:   make(map[string]int),
+		requests: make(map[string]int),
+	}
+
+	files, err := fixtures.ReadDir("testdata")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	for _, file := range files {
+		bz, err := fixtures.ReadFile(path.Join("testdata", file.Name()))
+		if err != nil {
+			tb.Fatal(err)
+		}
+
+		var pages map[string]json.RawMessage
+		if err := json.Unmarshal(bz, &pages); err != nil {
+			tb.Fatalf("%s: %v", file.Name(), err)
+		}
+		fake.pages[strings.TrimSuffix(file.Name(), ".json")] = pages
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", fake.handleHome)
+	mux.HandleFunc("/1.1/guest/activate.json", fake.handleGuestActivate)
+	mux.HandleFunc(onboardingTaskPath, fake.handleOnboardingTask)
+	mux.HandleFunc("/i/api/1.1/strato/column/User/", fake.handleSearchSafety)
+	mux.HandleFunc("/i/api/graphql/", fake.handleGraphQL)
+
+	fake.Server = httptest.NewTLSServer(mux)
+	tb.Cleanup(fake.Close)
+
+	return fake
+}
+
+// AddAccount registers an account which can log in with password.
+func (fake *Server) AddAccount(username string, password string) {
+	fake.mtx.Lock()
+	defer fake.mtx.Unlock()
+
+	fake.accounts[username] = fakeAccount{ID: strconv.Itoa(1000 + len(fake.accounts)), Password: password}
+}
+
+// SetRateLimit sets the rate limit window of a GraphQL operation, requests are answered with 429 once it is used up.
+func (fake *Server) SetRateLimit(operation string, remaining int64, reset time.Time) {
+	fake.mtx.Lock()
+	defer fake.mtx.Unlock()
+
+	fake.limits[operation] = &fakeRateLimit{remaining: remaining, reset: reset.Unix()}
+}
+
+// FailNext answers the next requests to an endpoint, a GraphQL operation or "home", "activate" and "task", with statusCodes in order.
+func (fake *Server) FailNext(endpoint string, statusCodes ...int) {
+	fake.mtx.Lock()
+	defer fake.mtx.Unlock()
+
+	fake.failures[endpoint] = append(fake.failures[endpoint], statusCodes...)
+}
+
+// ExpireSessions invalidates every session, as if Twitter logged the accounts out.
+func (fake *Server) ExpireSessions() {
+	fake.mtx.Lock()
+	defer fake.mtx.Unlock()
+
+	fake.sessions = make(map[string]string)
+}
+
+// LoginCount returns the number of successful logins of an account.
+func (fake *Server) LoginCount(username string) int {
+	fake.mtx.Lock()
+	defer fake.mtx.Unlock()
+
+	return fake.logins[username]
+}
+
+// RequestCount returns the number of requests to a GraphQL operation.
+func (fake *Server) RequestCount(operation string) int {
+	fake.mtx.Lock()
+	defer fake.mtx.Unlock()
+
+	return fake.requests[operation]
+}
+
+// injectedFailure pops the next failure of endpoint, mtx must be held.
+func (fake *Server) injectedFailure(endpoint string) (int, bool) {
+	failures := fake.failures[endpoint]
+	if len(failures) == 0 {
+		return 0, false
+	}
+
+	fake.failures[endpoint] = failures[1:]
+	return failures[0], true
+}
+
+func (fake *Server) handleHome(w http.ResponseWriter, r *http.Request) {
+	fake.mtx.Lock()
+	statusCode, failed := fake.injectedFailure("home")
+	fake.mtx.Unlock()
+
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	if failed {
+		w.WriteHeader(statusCode)
+		return
+	}
+
+	_, _ = fmt.Fprintf(w, `<html><script>document.cookie="gt=%s; Max-Age=10800; Domain=.twitter.com; Path=/; Secure";</script></html>`, fakeGuestToken)
+}
+
+func (fake *Server) handleGuestActivate(w http.ResponseWriter, r *http.Request) {
+	fake.mtx.Lock()
+	statusCode, failed := fake.injectedFailure("activate")
+	fake.mtx.Unlock()
+
+	if failed {
+		w.WriteHeader(statusCode)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeFakeJSON(w, http.StatusOK, map[string]interface{}{"guest_token": fakeGuestToken})
+}
+
+func (fake *Server) handleOnboardingTask(w http.ResponseWriter, r *http.Request) {
+	fake.mtx.Lock()
+	defer fake.mtx.Unlock()
+
+	if statusCode, failed := fake.injectedFailure("task"); failed {
+		w.WriteHeader(statusCode)
+		return
+	}
+	if r.Header.Get("X-Guest-Token") != fakeGuestToken {
+		writeFakeErrors(w, http.StatusBadRequest, apiError{Code: 239, Message: "Bad guest token."})
+		return
+	}
+
+	if r.URL.Query().Get("flow_name") == "login" {
+		flowToken := fmt.Sprintf("flow-%d", len(fake.flows))
+		fake.flows[flowToken] = &fakeFlow{next: fakeLoginSubtasks[0]}
+		writeFakeSubtask(w, flowToken, fakeLoginSubtasks[0])
+		return
+	}
+
+	var reqBody struct {
+		FlowToken     string `json:"flow_token"`
+		SubtaskInputs []struct {
+			SubtaskID    string `json:"subtask_id"`
+			SettingsList struct {
+				SettingResponses []struct {
+					ResponseData struct {
+						TextData struct {
+							Result string `json:"result"`
+						} `json:"text_data"`
+					} `json:"response_data"`
+				} `json:"setting_responses"`
+			} `json:"settings_list"`
+			EnterPassword struct {
+				Password string `json:"password"`
+			} `json:"enter_password"`
+		} `json:"subtask_inputs"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&reqBody)
+	flow, ok := fake.flows[reqBody.FlowToken]
+	if err != nil || !ok || len(reqBody.SubtaskInputs) == 0 || reqBody.SubtaskInputs[0].SubtaskID != flow.next {
+		writeFakeErrors(w, http.StatusBadRequest, apiError{Code: 366, Message: "flow name LoginFlow is currently not accessible"})
+		return
+	}
+
+	input := reqBody.SubtaskInputs[0]
+	switch input.SubtaskID {
+	case "LoginEnterUserIdentifierSSO":
+		if len(input.SettingsList.SettingResponses) > 0 {
+			flow.username = input.SettingsList.SettingResponses[0].ResponseData.TextData.Result
+		}
+		if _, ok := fake.accounts[flow.username]; !ok {
+			writeFakeErrors(w, http.StatusBadRequest, apiError{Code: 399, Message: "Sorry, we could not find your account."})
+			return
+		}
+	case "LoginEnterPassword":
+		if fake.accounts[flow.username].Password != input.EnterPassword.Password {
+			writeFakeErrors(w, http.StatusBadRequest, apiError{Code: 399, Message: "Wrong password!"})
+			return
+		}
+	}
+
+	for i, subtaskID := range fakeLoginSubtasks {
+		if subtaskID == flow.next {
+			flow.next = fakeLoginSubtasks[i+1]
+			break
+		}
+	}
+
+	if flow.next == loginSuccessSubtask {
+		fake.logins[flow.username]++
+		authToken := fmt.Sprintf("auth-%s-%d", flow.username, fake.logins[flow.username])
+		fake.sessions[authToken] = flow.username
+		http.SetCookie(w, &http.Cookie{Name: "auth_token", Value: authToken, Path: "/", Secure: true, HttpOnly: true})
+		http.SetCookie(w, &http.Cookie{Name: "ct0", Value: "csrf-" + authToken, Path: "/", Secure: true})
+		http.SetCookie(w, &http.Cookie{Name: "twid", Value: "u=" + fake.accounts[flow.username].ID, Path: "/", Secure: true})
+	}
+
+	writeFakeSubtask(w, reqBody.FlowToken, flow.next)
+}
+
+func (fake *Server) handleSearchSafety(w http.ResponseWriter, r *http.Request) {
+	fake.mtx.Lock()
+	defer fake.mtx.Unlock()
+
+	if _, ok := fake.authenticate(r); !ok {
+		writeFakeErrors(w, http.StatusUnauthorized, apiError{Code: 32, Message: "Could not authenticate you."})
+		return
+	}
+
+	writeFakeJSON(w, http.StatusOK, map[string]interface{}{"optInFiltering": true, "optInBlocking": true})
+}
+
+func (fake *Server) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	fake.mtx.Lock()
+	defer fake.mtx.Unlock()
+
+	matches := regexFakeGraphQLPath.FindStringSubmatch(r.URL.Path)
+	if len(matches) != 2 || fake.pages[matches[1]] == nil {
+		http.NotFound(w, r)
+		return
+	}
+	operation := matches[1]
+	fake.requests[operation]++
+
+	limit, ok := fake.limits[operation]
+	if !ok || limit.reset < time.Now().Unix() {
+		limit = &fakeRateLimit{remaining: 500, reset: time.Now().Add(15 * time.Minute).Unix()}
+		fake.limits[operation] = limit
+	}
+	w.Header().Set("X-Rate-Limit-Reset", strconv.FormatInt(limit.reset, 10))
+
+	if statusCode, failed := fake.injectedFailure(operation); failed {
+		w.Header().Set("X-Rate-Limit-Remaining", strconv.FormatInt(limit.remaining, 10))
+		writeFakeJSON(w, statusCode, map[string]interface{}{})
+		return
+	}
+
+	if _, ok := fake.authenticate(r); !ok {
+		w.Header().Set("X-Rate-Limit-Remaining", strconv.FormatInt(limit.remaining, 10))
+		writeFakeErrors(w, http.StatusUnauthorized, apiError{Code: 32, Message: "Could not authenticate you."})
+		return
+	}
+
+	if limit.remaining <= 0 {
+		w.Header().Set("X-Rate-Limit-Remaining", "0")
+		writeFakeErrors(w, http.StatusTooManyRequests, apiError{Code: 88, Message: "Rate limit exceeded."})
+		return
+	}
+	limit.remaining--
+	w.Header().Set("X-Rate-Limit-Remaining", strconv.FormatInt(limit.remaining, 10))
+
+	var variables map[string]interface{}
+	_ = json.Unmarshal([]byte(r.URL.Query().Get("variables")), &variables)
+
+	if bulkKey, ok := fakeBulkLookupKeys[operation]; ok {
+		keys, _ := variables[bulkKey].([]interface{})
+		items := make([]json.RawMessage, 0, len(keys))
+		for _, key := range keys {
+			key, _ := key.(string)
+			item, ok := fake.pages[operation][key]
+			if !ok {
+				item = json.RawMessage("{}")
+			}
+			items = append(items, item)
+		}
+		writeFakeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"users": items}})
+		return
+	}
+
+	lookupKeys, isLookup := fakeLookupKeys[operation]
+	if !isLookup {
+		lookupKeys = []string{"cursor"}
+	}
+	keys := make([]string, 0, len(lookupKeys))
+	for _, lookupKey := range lookupKeys {
+		key, _ := variables[lookupKey].(string)
+		keys = append(keys, key)
+	}
+	key := strings.Join(keys, " ")
+
+	page, ok := fake.pages[operation][key]
+	switch {
+	case !ok && isLookup:
+		writeFakeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{}})
+		return
+	case !ok:
+		writeFakeErrors(w, http.StatusOK, apiError{Code: 214, Message: "Bad cursor", Name: "BadRequestError", Kind: "Validation"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(page)
+}
+
+// authenticate checks the session cookies and the CSRF token of r, mtx must be held.
+func (fake *Server) authenticate(r *http.Request) (string, bool) {
+	authToken, err := r.Cookie("auth_token")
+	if err != nil {
+		return "", false
+	}
+	username, ok := fake.sessions[authToken.Value]
+	if !ok {
+		return "", false
+	}
+	if r.Header.Get("X-Csrf-Token") != "csrf-"+authToken.Value {
+		return "", false
+	}
+
+	return username, true
+}
+
+// apiError is an error of Twitter's errors payload.
+type apiError struct {
+	Message string `json:"message"`
+	Name    string `json:"name"`
+	Kind    string `json:"kind"`
+	Code    int    `json:"code"`
+}
+
+func writeFakeSubtask(w http.ResponseWriter, flowToken string, subtaskID string) {
+	writeFakeJSON(w, http.StatusOK, map[string]interface{}{
+		"flow_token": flowToken,
+		"status":     "success",
+		"subtasks":   []map[string]interface{}{{"subtask_id": subtaskID}},
+	})
+}
+
+func writeFakeErrors(w http.ResponseWriter, statusCode int, errs ...apiError) {
+	writeFakeJSON(w, statusCode, map[string]interface{}{"errors": errs})
+}
+
+func writeFakeJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(v)
+}
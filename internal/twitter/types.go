@@ -50,6 +50,30 @@ type Following struct {
 	ScreenName string
 }
 
+type Follower struct {
+	TargetID   string
+	UserID     string
+	Name       string
+	ScreenName string
+}
+
+type User struct {
+	ID              string
+	ScreenName      string
+	Name            string
+	Description     string
+	Location        string
+	CreatedAt       time.Time
+	FollowersCount  int64
+	FollowingCount  int64
+	StatusesCount   int64
+	Verified        bool // legacy verification
+	BlueVerified    bool
+	Protected       bool
+	Suspended       bool // only ID or ScreenName is set for suspended users
+	ProfileImageURL string
+}
+
 type StatusStat struct {
 	UserID         string
 	UserScreenName string
@@ -64,11 +88,48 @@ type StatusStat struct {
 	FavoriteCount  int64
 }
 
+type Tweet struct {
+	ID                string
+	ConversationID    string
+	InReplyToStatusID string
+	UserID            string
+	UserScreenName    string
+	UserName          string
+	Text              string
+	NormalizedText    string
+	CreatedAt         time.Time
+	Hashtags          []string
+	Symbols           []string
+	ViewCount         int64
+	QuoteCount        int64
+	ReplyCount        int64
+	RetweetCount      int64
+	FavoriteCount     int64
+	QuotedStatus      *Tweet
+	RetweetedStatus   *Tweet
+}
+
+// Thread is a conversation module of a tweet detail: a reply followed by the replies continuing it.
+type Thread struct {
+	Tweets []Tweet
+	Cursor string // shows more of the thread, if any, when passed to TweetDetail
+}
+
+type Conversation struct {
+	Tweet   *Tweet // the focal tweet, only on the first page
+	Threads []Thread
+}
+
 type ICrawlAPI interface {
 	Replies(ctx context.Context, tweetID string, cursor string) ([]Reply, string, error)
 	Quotes(ctx context.Context, tweetID string, cursor string) ([]Quote, string, error)
 	Retweets(ctx context.Context, tweetID string, cursor string) ([]Retweet, string, error)
 	Likes(ctx context.Context, tweetID string, cursor string) ([]Like, string, error)
 	Following(ctx context.Context, targetID string, cursor string) ([]Following, string, error)
+	Followers(ctx context.Context, targetID string, cursor string) ([]Follower, string, error)
+	UserByScreenName(ctx context.Context, screenName string) (*User, error)
+	UserByRestID(ctx context.Context, userID string) (*User, error)
+	UsersByRestIDs(ctx context.Context, ids []string) ([]User, []string, error)
+	TweetDetail(ctx context.Context, tweetID string, cursor string) (*Conversation, string, error)
 	StatusesByScreenName(ctx context.Context, userID string, cursor string) ([]StatusStat, string, error)
 }